@@ -0,0 +1,275 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/magefile/mage/sh"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// BuildConfig configures Build
+type BuildConfig struct {
+	// Package to build, defaults to "."
+	Package string
+	// Output is the path of the binary, defaults to BinDir/<package name>
+	Output string
+	// BinDir is where binaries are put when Output is not set, defaults to "build"
+	BinDir string
+	// LDFlagsVars are injected with -X, e.g. {"main.appName": "cli"}
+	LDFlagsVars map[string]string
+	// VersionVar receives the git describe output, e.g. "main.version"
+	VersionVar string
+	// CommitVar receives the HEAD commit SHA, e.g. "main.commit"
+	CommitVar string
+	// BuildTimeVar receives the UTC build time in RFC3339, e.g. "main.buildTime"
+	BuildTimeVar string
+	// Tags are build tags to use
+	Tags []string
+	// TrimPath removes file system paths from the binary
+	TrimPath bool
+	// CGO sets CGO_ENABLED when not nil
+	CGO *bool
+	// Env is added to the go build environment, e.g. {"GOOS": "linux"}
+	Env map[string]string
+}
+
+// Build builds a binary injecting version information with ldflags
+//
+// Example:
+//     commands.Build(commands.BuildConfig{Package: "./cmd/app", VersionVar: "main.version", CommitVar: "main.commit"})
+func Build(cfg BuildConfig) error {
+	_, err := build(cfg, os.Stderr)
+	return err
+}
+
+// build builds the binary writing go build errors to stderr and returns its path
+func build(cfg BuildConfig, stderr io.Writer) (string, error) {
+	if cfg.Package == "" {
+		cfg.Package = "."
+	}
+	env := buildEnv(cfg)
+
+	name, err := mainPackageName(cfg.Package, env)
+	if err != nil {
+		return "", err
+	}
+
+	output := cfg.Output
+	if output == "" {
+		output = filepath.Join(binDir(cfg), name)
+		if env["GOOS"] == "windows" || (env["GOOS"] == "" && os.Getenv("GOOS") == "windows") {
+			output += ".exe"
+		}
+	}
+
+	ldflags, err := buildLDFlags(cfg)
+	if err != nil {
+		return "", err
+	}
+	args := []string{"build", "-o", output}
+	if cfg.TrimPath {
+		args = append(args, "-trimpath")
+	}
+	if len(cfg.Tags) != 0 {
+		args = append(args, "-tags", strings.Join(cfg.Tags, ","))
+	}
+	if ldflags != "" {
+		args = append(args, "-ldflags", ldflags)
+	}
+	args = append(args, cfg.Package)
+
+	if _, err := sh.Exec(env, os.Stdout, stderr, "go", args...); err != nil {
+		fmt.Printf("build: could not build %s: %s\n", cfg.Package, err)
+		return "", err
+	}
+	info, err := os.Stat(output)
+	if err != nil {
+		return "", err
+	}
+	absOutput, err := filepath.Abs(output)
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("build: %s (%.1f MB)\n", absOutput, float64(info.Size())/(1<<20))
+	return absOutput, nil
+}
+
+// mainPackageName returns the last element of the package import path, failing when it is not a main package
+func mainPackageName(pkg string, env map[string]string) (string, error) {
+	out, err := sh.OutputWith(env, "go", "list", "-f", "{{.Name}} {{.ImportPath}}", pkg)
+	if err != nil {
+		fmt.Printf("build: package %s does not build: %s\n", pkg, err)
+		return "", err
+	}
+	fields := strings.Fields(out)
+	if len(fields) != 2 || fields[0] != "main" {
+		return "", errors.Errorf("build: package %s is not a main package", pkg)
+	}
+	return filepath.Base(fields[1]), nil
+}
+
+func binDir(cfg BuildConfig) string {
+	if cfg.BinDir == "" {
+		return "build"
+	}
+	return cfg.BinDir
+}
+
+func buildEnv(cfg BuildConfig) map[string]string {
+	env := make(map[string]string)
+	for k, v := range cfg.Env {
+		env[k] = v
+	}
+	if cfg.CGO != nil {
+		env["CGO_ENABLED"] = "0"
+		if *cfg.CGO {
+			env["CGO_ENABLED"] = "1"
+		}
+	}
+	return env
+}
+
+func buildLDFlags(cfg BuildConfig) (string, error) {
+	vars := make(map[string]string)
+	for k, v := range cfg.LDFlagsVars {
+		vars[k] = v
+	}
+	if cfg.VersionVar != "" || cfg.CommitVar != "" {
+		version, commit, err := gitDescribe()
+		if err != nil {
+			return "", errors.Wrap(err, "build: could not describe git HEAD")
+		}
+		if cfg.VersionVar != "" {
+			vars[cfg.VersionVar] = version
+		}
+		if cfg.CommitVar != "" {
+			vars[cfg.CommitVar] = commit
+		}
+	}
+	if cfg.BuildTimeVar != "" {
+		vars[cfg.BuildTimeVar] = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	flags := make([]string, 0, len(keys))
+	for _, k := range keys {
+		flags = append(flags, "-X "+quoteLDFlag(k+"="+vars[k]))
+	}
+	return strings.Join(flags, " "), nil
+}
+
+// quoteLDFlag quotes the value for the go command's -ldflags splitting, which does not support escapes
+func quoteLDFlag(v string) string {
+	if strings.Contains(v, "'") {
+		return `"` + v + `"`
+	}
+	return "'" + v + "'"
+}
+
+// describeCandidates is how many of the nearest tags gitDescribe compares, like `git describe --candidates`
+const describeCandidates = 10
+
+// gitDescribe returns a `git describe --tags` like version and the full SHA of HEAD.
+// The distance to a tag is the number of commits reachable from HEAD but not from the tag, so merged branches count too.
+// Without any reachable tag the version is the short SHA.
+func gitDescribe() (string, string, error) {
+	repo, err := git.PlainOpenWithOptions("./", &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return "", "", err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", "", err
+	}
+	commit := head.Hash().String()
+
+	tagIter, err := repo.Tags()
+	if err != nil {
+		return "", "", err
+	}
+	tags := make(map[plumbing.Hash]string)
+	err = tagIter.ForEach(func(ref *plumbing.Reference) error {
+		hash := ref.Hash()
+		if tag, err := repo.TagObject(hash); err == nil {
+			hash = tag.Target
+		}
+		tags[hash] = ref.Name().Short()
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	reachable, candidates, err := commitAncestors(repo, head.Hash(), tags)
+	if err != nil {
+		return "", "", err
+	}
+	if len(candidates) == 0 {
+		return commit[:7], commit, nil
+	}
+	best, bestDistance := plumbing.ZeroHash, -1
+	for _, candidate := range candidates {
+		ancestors, _, err := commitAncestors(repo, candidate, nil)
+		if err != nil {
+			return "", "", err
+		}
+		if distance := len(reachable) - len(ancestors); bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	if bestDistance == 0 {
+		return tags[best], commit, nil
+	}
+	return fmt.Sprintf("%s-%d-g%s", tags[best], bestDistance, commit[:7]), commit, nil
+}
+
+// commitAncestors walks the commit graph breadth first from the given commit and returns all commits reachable from it.
+// It also returns up to describeCandidates tagged commits, nearest first.
+func commitAncestors(repo *git.Repository, from plumbing.Hash, tags map[plumbing.Hash]string) (map[plumbing.Hash]bool, []plumbing.Hash, error) {
+	seen := map[plumbing.Hash]bool{from: true}
+	var candidates []plumbing.Hash
+	queue := []plumbing.Hash{from}
+	for len(queue) != 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if _, ok := tags[hash]; ok && len(candidates) < describeCandidates {
+			candidates = append(candidates, hash)
+		}
+		c, err := repo.CommitObject(hash)
+		if err == plumbing.ErrObjectNotFound && hash != from {
+			// parents beyond a shallow clone boundary are missing
+			delete(seen, hash)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, parent := range c.ParentHashes {
+			if !seen[parent] {
+				seen[parent] = true
+				queue = append(queue, parent)
+			}
+		}
+	}
+	return seen, candidates, nil
+}
@@ -0,0 +1,273 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/magefile/mage/sh"
+	"github.com/pkg/errors"
+)
+
+// Target is a single GOOS/GOARCH combination of a build matrix
+type Target struct {
+	GOOS   string
+	GOARCH string
+	// Suffix is appended to the binary name, e.g. ".exe"
+	Suffix string
+}
+
+func (t Target) String() string {
+	return t.GOOS + "/" + t.GOARCH
+}
+
+// Artifact is a binary produced by BuildMatrix
+type Artifact struct {
+	Target Target
+	Path   string
+	SHA256 string
+}
+
+// MatrixConfig configures BuildMatrixWith
+type MatrixConfig struct {
+	// Parallelism is the number of concurrent builds, defaults to 1
+	Parallelism int
+	// Strict fails on targets that need CGO cross-compilation instead of skipping them
+	Strict bool
+}
+
+// BuildMatrix builds the package for every target into BinDir, naming binaries <name>_<goos>_<goarch><suffix>,
+// and writes checksums.txt with sha256 sums of all of them next to the binaries.
+//
+// Unless cfg.CGO is set, cross-compiled targets are built with CGO disabled.
+// Targets that then fail only because they depend on cgo packages are skipped with a warning,
+// any other build error fails the target.
+//
+// Example:
+//     commands.BuildMatrix(commands.BuildConfig{Package: "./cmd/app", VersionVar: "main.version"}, []commands.Target{
+//         {GOOS: "linux", GOARCH: "amd64"},
+//         {GOOS: "linux", GOARCH: "arm64"},
+//         {GOOS: "darwin", GOARCH: "arm64"},
+//         {GOOS: "windows", GOARCH: "amd64", Suffix: ".exe"},
+//     })
+func BuildMatrix(cfg BuildConfig, targets []Target) ([]Artifact, error) {
+	return BuildMatrixWith(MatrixConfig{}, cfg, targets)
+}
+
+// BuildMatrixWith builds the package for every target like BuildMatrix using the given matrix config.
+//
+// Example:
+//     commands.BuildMatrixWith(commands.MatrixConfig{Parallelism: 4, Strict: true}, commands.BuildConfig{Package: "./cmd/app"}, targets)
+func BuildMatrixWith(matrix MatrixConfig, cfg BuildConfig, targets []Target) ([]Artifact, error) {
+	if cfg.Package == "" {
+		cfg.Package = "."
+	}
+	name := strings.TrimSuffix(filepath.Base(cfg.Output), filepath.Ext(cfg.Output))
+	if cfg.Output == "" {
+		var err error
+		name, err = mainPackageName(cfg.Package, buildEnv(cfg))
+		if err != nil {
+			return nil, err
+		}
+	}
+	parallelism := matrix.Parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	var (
+		mu        sync.Mutex
+		artifacts []Artifact
+		failures  []string
+		wg        sync.WaitGroup
+		sem       = make(chan struct{}, parallelism)
+	)
+	for _, target := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(target Target) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			artifact, skipped, err := buildTarget(cfg, name, target, matrix.Strict)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err != nil:
+				failures = append(failures, fmt.Sprintf("%s: %s", target, err))
+			case !skipped:
+				artifacts = append(artifacts, artifact)
+			}
+		}(target)
+	}
+	wg.Wait()
+
+	if len(failures) != 0 {
+		sort.Strings(failures)
+		return artifacts, errors.Errorf("build: %d target(s) failed:\n%s", len(failures), strings.Join(failures, "\n"))
+	}
+	if len(artifacts) == 0 {
+		fmt.Println("build: no binaries were built, skipping checksums")
+		return nil, nil
+	}
+	sort.Slice(artifacts, func(i, j int) bool {
+		return artifacts[i].Path < artifacts[j].Path
+	})
+	if err := writeChecksums(filepath.Join(binDir(cfg), "checksums.txt"), artifacts); err != nil {
+		return artifacts, err
+	}
+	return artifacts, nil
+}
+
+func buildTarget(cfg BuildConfig, name string, target Target, strict bool) (Artifact, bool, error) {
+	targetCfg := cfg
+	targetCfg.Env = make(map[string]string)
+	for k, v := range cfg.Env {
+		targetCfg.Env[k] = v
+	}
+	targetCfg.Env["GOOS"] = target.GOOS
+	targetCfg.Env["GOARCH"] = target.GOARCH
+	targetCfg.Output = filepath.Join(binDir(cfg), fmt.Sprintf("%s_%s_%s%s", name, target.GOOS, target.GOARCH, target.Suffix))
+	crossCompiling := target.GOOS != runtime.GOOS || target.GOARCH != runtime.GOARCH
+	if crossCompiling && targetCfg.CGO == nil {
+		cgo := false
+		targetCfg.CGO = &cgo
+	}
+
+	var stderr bytes.Buffer
+	path, err := build(targetCfg, io.MultiWriter(os.Stderr, &stderr))
+	if err != nil {
+		if !crossCompiling || cfg.CGO != nil {
+			return Artifact{}, false, err
+		}
+		// only a failure caused by disabling CGO makes the target skippable
+		cgoPkgs, cgoErr := cgoFailures(targetCfg, stderr.String())
+		if cgoErr != nil || len(cgoPkgs) == 0 {
+			return Artifact{}, false, err
+		}
+		msg := fmt.Sprintf("cross-compiling requires CGO for %s", strings.Join(cgoPkgs, ", "))
+		if strict {
+			return Artifact{}, false, errors.New(msg)
+		}
+		fmt.Printf("build: WARNING skipping %s: %s\n", target, msg)
+		return Artifact{}, true, nil
+	}
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return Artifact{}, false, err
+	}
+	return Artifact{Target: target, Path: path, SHA256: sum}, false, nil
+}
+
+// cgoFailures returns the cgo packages that failed a build with CGO disabled.
+// It returns nothing when any other package failed too, so real build errors are not mistaken for missing CGO.
+func cgoFailures(cfg BuildConfig, stderr string) ([]string, error) {
+	failed, err := loadErrorPackages(cfg)
+	if err != nil {
+		return nil, err
+	}
+	// go build prints "# <import path>" before the compile errors of every package
+	for _, line := range strings.Split(stderr, "\n") {
+		if strings.HasPrefix(line, "# ") {
+			failed = append(failed, strings.TrimSpace(strings.TrimPrefix(line, "# ")))
+		}
+	}
+	if len(failed) == 0 {
+		return nil, nil
+	}
+	cgo, err := cgoPackages(cfg)
+	if err != nil {
+		return nil, err
+	}
+	isCgo := make(map[string]bool, len(cgo))
+	for _, pkg := range cgo {
+		isCgo[pkg] = true
+	}
+	seen := make(map[string]bool)
+	var pkgs []string
+	for _, pkg := range failed {
+		if !isCgo[pkg] {
+			return nil, nil
+		}
+		if !seen[pkg] {
+			seen[pkg] = true
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	sort.Strings(pkgs)
+	return pkgs, nil
+}
+
+// loadErrorPackages returns the packages the build depends on that cannot be loaded with the build environment,
+// e.g. cgo only packages when CGO is disabled
+func loadErrorPackages(cfg BuildConfig) ([]string, error) {
+	args := []string{"list", "-e", "-deps", "-f", "{{if .Error}}{{.ImportPath}}{{end}}"}
+	if len(cfg.Tags) != 0 {
+		args = append(args, "-tags", strings.Join(cfg.Tags, ","))
+	}
+	out, err := sh.OutputWith(buildEnv(cfg), "go", append(args, cfg.Package)...)
+	if err != nil {
+		return nil, err
+	}
+	return nonEmpty(strings.Split(out, "\n")), nil
+}
+
+// cgoPackages returns the non standard library packages with cgo files the build depends on when CGO is enabled
+func cgoPackages(cfg BuildConfig) ([]string, error) {
+	env := buildEnv(cfg)
+	env["CGO_ENABLED"] = "1"
+	args := []string{"list", "-deps", "-f", "{{if and (not .Standard) .CgoFiles}}{{.ImportPath}}{{end}}"}
+	if len(cfg.Tags) != 0 {
+		args = append(args, "-tags", strings.Join(cfg.Tags, ","))
+	}
+	out, err := sh.OutputWith(env, "go", append(args, cfg.Package)...)
+	if err != nil {
+		return nil, err
+	}
+	return nonEmpty(strings.Split(out, "\n")), nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeChecksums writes sums in the sha256sum format
+func writeChecksums(filename string, artifacts []Artifact) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	var b strings.Builder
+	for _, a := range artifacts {
+		fmt.Fprintf(&b, "%s  %s\n", a.SHA256, filepath.Base(a.Path))
+	}
+	if err := ioutil.WriteFile(filename, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("build: checksums written to %s\n", filename)
+	return nil
+}
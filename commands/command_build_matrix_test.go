@@ -0,0 +1,104 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const matrixFixtureMain = `package main
+
+import "example.com/fixture/native"
+
+func main() {
+	native.Hello()
+}
+`
+
+// crossTarget returns a target that differs from the host
+func crossTarget() Target {
+	if runtime.GOOS == "linux" && runtime.GOARCH == "arm64" {
+		return Target{GOOS: "linux", GOARCH: "amd64"}
+	}
+	return Target{GOOS: "linux", GOARCH: "arm64"}
+}
+
+func TestBuildMatrixSkipsCgoTargets(t *testing.T) {
+	files := map[string]string{
+		"main.go":          matrixFixtureMain,
+		"native/native.go": "package native\n\n// #include <stdio.h>\nimport \"C\"\n\nfunc Hello() {}\n",
+	}
+	inFixtureModule(t, files, func(dir string) {
+		artifacts, err := BuildMatrix(BuildConfig{}, []Target{crossTarget()})
+		if err != nil {
+			t.Fatalf("BuildMatrix() error = %v", err)
+		}
+		if len(artifacts) != 0 {
+			t.Errorf("BuildMatrix() = %v, want the cgo target skipped", artifacts)
+		}
+		if _, err := os.Stat(filepath.Join("build", "checksums.txt")); !os.IsNotExist(err) {
+			t.Errorf("checksums.txt written without any binaries: %v", err)
+		}
+
+		_, err = BuildMatrixWith(MatrixConfig{Strict: true}, BuildConfig{}, []Target{crossTarget()})
+		if err == nil || !strings.Contains(err.Error(), "requires CGO for example.com/fixture/native") {
+			t.Errorf("strict BuildMatrixWith() error = %v, want the cgo package reported", err)
+		}
+	})
+}
+
+func TestBuildMatrixCgoFallback(t *testing.T) {
+	files := map[string]string{
+		"main.go":                matrixFixtureMain,
+		"native/native_cgo.go":   "// +build cgo\n\npackage native\n\n// #include <stdio.h>\nimport \"C\"\n\nfunc Hello() {}\n",
+		"native/native_nocgo.go": "// +build !cgo\n\npackage native\n\nfunc Hello() {}\n",
+	}
+	inFixtureModule(t, files, func(dir string) {
+		target := crossTarget()
+		artifacts, err := BuildMatrixWith(MatrixConfig{Strict: true}, BuildConfig{}, []Target{target})
+		if err != nil {
+			t.Fatalf("BuildMatrixWith() error = %v", err)
+		}
+		if len(artifacts) != 1 || artifacts[0].Target != target {
+			t.Fatalf("BuildMatrixWith() = %v, want one %s binary", artifacts, target)
+		}
+		sums, err := ioutil.ReadFile(filepath.Join("build", "checksums.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := artifacts[0].SHA256 + "  fixture_" + target.GOOS + "_" + target.GOARCH + "\n"
+		if string(sums) != want {
+			t.Errorf("checksums.txt = %q, want %q", sums, want)
+		}
+	})
+}
+
+func TestBuildMatrixReportsBuildErrors(t *testing.T) {
+	target := crossTarget()
+	files := map[string]string{
+		"main.go":                matrixFixtureMain,
+		"native/native_cgo.go":   "// +build cgo\n\npackage native\n\n// #include <stdio.h>\nimport \"C\"\n\nfunc Hello() {}\n",
+		"native/native_nocgo.go": "// +build !cgo\n\npackage native\n\nfunc Hello() {}\n",
+		"broken_" + target.GOOS + "_" + target.GOARCH + ".go": "package main\n\nvar broken int = \"not an int\"\n",
+	}
+	inFixtureModule(t, files, func(dir string) {
+		artifacts, err := BuildMatrix(BuildConfig{}, []Target{target})
+		if err == nil {
+			t.Fatalf("BuildMatrix() = %v, want the compile error reported", artifacts)
+		}
+		if strings.Contains(err.Error(), "requires CGO") {
+			t.Errorf("BuildMatrix() error = %v, want the compile error instead of a CGO skip", err)
+		}
+	})
+}
@@ -0,0 +1,149 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+const buildFixtureMain = `package main
+
+import "fmt"
+
+var version, commit, appName string
+
+func main() {
+	fmt.Println(version, commit, appName)
+}
+`
+
+// gitCommitTime is advanced for every git call, git describe walks commits by date and needs them distinct
+var gitCommitTime = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// runGit runs git in the current directory with a fixed identity and fails the test on errors
+func runGit(t *testing.T, args ...string) string {
+	t.Helper()
+	args = append([]string{"-c", "user.name=go-ci", "-c", "user.email=go-ci@example.com", "-c", "commit.gpgsign=false", "-c", "tag.gpgsign=false"}, args...)
+	gitCommitTime = gitCommitTime.Add(time.Minute)
+	date := gitCommitTime.Format(time.RFC3339)
+	cmd := exec.Command("git", args...)
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %s\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// gitCommit commits a change to the given file
+func gitCommit(t *testing.T, file, msg string) {
+	t.Helper()
+	if err := ioutil.WriteFile(file, []byte(msg+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, "add", file)
+	runGit(t, "commit", "-q", "-m", msg)
+}
+
+func TestGitDescribe(t *testing.T) {
+	requireTool(t, "git")
+	inFixtureModule(t, map[string]string{"main.go": buildFixtureMain}, func(dir string) {
+		runGit(t, "init", "-q")
+		runGit(t, "checkout", "-q", "-b", "master")
+		gitCommit(t, "a.txt", "initial")
+
+		version, commit, err := gitDescribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := runGit(t, "rev-parse", "HEAD"); commit != want || version != want[:7] {
+			t.Errorf("without tags gitDescribe() = %q, %q, want %q, %q", version, commit, want[:7], want)
+		}
+
+		runGit(t, "tag", "-a", "-m", "first release", "v1.0.0")
+		version, _, err = gitDescribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != "v1.0.0" {
+			t.Errorf("on a tag gitDescribe() = %q, want v1.0.0", version)
+		}
+
+		// two commits on a branch and one on master, then a merge: four commits since the tag
+		runGit(t, "checkout", "-q", "-b", "feature")
+		gitCommit(t, "b.txt", "feature 1")
+		gitCommit(t, "b.txt", "feature 2")
+		runGit(t, "checkout", "-q", "master")
+		gitCommit(t, "a.txt", "master 1")
+		runGit(t, "merge", "-q", "--no-ff", "-m", "merge feature", "feature")
+
+		version, _, err = gitDescribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := runGit(t, "describe", "--tags"); version != want {
+			t.Errorf("after a merge gitDescribe() = %q, want %q", version, want)
+		}
+		if !strings.HasPrefix(version, "v1.0.0-4-g") {
+			t.Errorf("after a merge gitDescribe() = %q, want distance 4", version)
+		}
+
+		// a lightweight tag on the branch is nearer than the release tag
+		runGit(t, "tag", "v1.1.0-rc1", "feature")
+		version, _, err = gitDescribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := runGit(t, "describe", "--tags"); version != want {
+			t.Errorf("with a nearer tag gitDescribe() = %q, want %q", version, want)
+		}
+	})
+}
+
+func TestBuildInjectsVariables(t *testing.T) {
+	requireTool(t, "git")
+	inFixtureModule(t, map[string]string{"main.go": buildFixtureMain}, func(dir string) {
+		runGit(t, "init", "-q")
+		runGit(t, "add", "-A")
+		runGit(t, "commit", "-q", "-m", "initial")
+		runGit(t, "tag", "v0.3.0")
+		gitCommit(t, "a.txt", "next")
+
+		output := filepath.Join(dir, "bin", "app")
+		if runtime.GOOS == "windows" {
+			output += ".exe"
+		}
+		err := Build(BuildConfig{
+			Output:      output,
+			VersionVar:  "main.version",
+			CommitVar:   "main.commit",
+			LDFlagsVars: map[string]string{"main.appName": "it's app"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := exec.Command(output).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		commit := runGit(t, "rev-parse", "HEAD")
+		want := "v0.3.0-1-g" + commit[:7] + " " + commit + " it's app"
+		if got := strings.TrimSpace(string(out)); got != want {
+			t.Errorf("binary printed %q, want %q", got, want)
+		}
+	})
+}
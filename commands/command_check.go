@@ -70,7 +70,7 @@ func CheckD(dir string, excludes ...string) error {
 		return GoLintD(dir, excludes...)
 	}
 	goVetWrapper := func() error {
-		return GoVet(dir)
+		return GoVetD(dir, excludes...)
 	}
 	mg.Deps(copyrightWrapper, importsWrapper, goLintWrapper, goVetWrapper)
 	return nil
@@ -89,7 +89,7 @@ func CheckFmtD(dir string, excludes ...string) error {
 		return GoLintD(dir, excludes...)
 	}
 	goVetWrapper := func() error {
-		return GoVet(dir)
+		return GoVetD(dir, excludes...)
 	}
 	mg.Deps(copyrightWrapper, importsWrapper, goLintWrapper, goVetWrapper)
 	return nil
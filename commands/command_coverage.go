@@ -0,0 +1,232 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/magefile/mage/sh"
+	"github.com/pkg/errors"
+)
+
+// CoverageDiffConfig configures CoverageDiff
+type CoverageDiffConfig struct {
+	// FailOnDrop turns a total coverage drop larger than MaxDrop into an error
+	FailOnDrop bool
+	// MaxDrop is the allowed total coverage drop in percentage points
+	MaxDrop float64
+}
+
+// PackageCoverageDiff holds base and head coverage of a single package
+type PackageCoverageDiff struct {
+	Package string
+	Base    float64
+	Head    float64
+	Delta   float64
+	// New is set for packages missing from the base profile
+	New bool
+}
+
+// CoverageDiffResult holds total and per package coverage deltas, packages sorted by delta ascending
+type CoverageDiffResult struct {
+	BaseTotal float64
+	HeadTotal float64
+	Delta     float64
+	Packages  []PackageCoverageDiff
+}
+
+// Dropped returns packages whose coverage decreased
+func (r CoverageDiffResult) Dropped() []PackageCoverageDiff {
+	var dropped []PackageCoverageDiff
+	for _, p := range r.Packages {
+		if !p.New && p.Delta < 0 {
+			dropped = append(dropped, p)
+		}
+	}
+	return dropped
+}
+
+// CoverageDiff compares two cover profiles, e.g. the one stored as an artifact of the base branch build and the one of the current build.
+//
+// Example:
+//     commands.CoverageDiff(commands.CoverageDiffConfig{FailOnDrop: true, MaxDrop: 0.5}, "base/cover.out", "cover.out")
+func CoverageDiff(cfg CoverageDiffConfig, baseProfile, headProfile string) (CoverageDiffResult, error) {
+	base, err := readCoverProfile(baseProfile)
+	if err != nil {
+		fmt.Printf("coverage: could not read base profile: %s\n", err)
+		return CoverageDiffResult{}, err
+	}
+	head, err := readCoverProfile(headProfile)
+	if err != nil {
+		fmt.Printf("coverage: could not read head profile: %s\n", err)
+		return CoverageDiffResult{}, err
+	}
+
+	result := diffCoverage(base, head)
+	fmt.Printf("coverage: total %.1f%% -> %.1f%% (%+.1f)\n", result.BaseTotal, result.HeadTotal, result.Delta)
+	if dropped := result.Dropped(); len(dropped) != 0 {
+		fmt.Println("coverage: the following packages dropped coverage:")
+		for _, p := range dropped {
+			fmt.Printf("%6.1f%% -> %6.1f%% (%+.1f)  %s\n", p.Base, p.Head, p.Delta, p.Package)
+		}
+	}
+	if cfg.FailOnDrop && -result.Delta > cfg.MaxDrop {
+		return result, errors.Errorf("coverage: total coverage dropped by %.1f, allowed %.1f", -result.Delta, cfg.MaxDrop)
+	}
+	return result, nil
+}
+
+// CoverageHTML renders a cover profile as an HTML report with `go tool cover -html`.
+// A missing profile or one without any coverage blocks is reported as such instead of the toolchain error.
+//
+// Example:
+//     commands.CoverageHTML("cover.out", "build/coverage.html")
+func CoverageHTML(profile, outPath string) error {
+	contents, err := ioutil.ReadFile(profile)
+	if os.IsNotExist(err) {
+		return errors.Errorf("coverage: profile %s does not exist, run the tests with -coverprofile first", profile)
+	}
+	if err != nil {
+		return err
+	}
+	blocks := nonEmpty(strings.Split(string(contents), "\n"))
+	if len(blocks) == 0 || (len(blocks) == 1 && strings.HasPrefix(blocks[0], "mode:")) {
+		return errors.Errorf("coverage: profile %s is empty", profile)
+	}
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return err
+	}
+	if err := sh.Run("go", "tool", "cover", "-html="+profile, "-o", outPath); err != nil {
+		fmt.Printf("coverage: could not generate HTML report: %s\n", err)
+		return err
+	}
+	absPath, err := filepath.Abs(outPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("coverage: HTML report written to %s\n", absPath)
+	fmt.Printf("coverage: file://%s\n", filepath.ToSlash(absPath))
+	return nil
+}
+
+// coverStatements counts statements of a profile per package
+type coverStatements struct {
+	total, covered int
+}
+
+func (s coverStatements) percent() float64 {
+	if s.total == 0 {
+		return 0
+	}
+	return float64(s.covered) / float64(s.total) * 100
+}
+
+type coverProfile map[string]coverStatements
+
+func (p coverProfile) total() coverStatements {
+	var sum coverStatements
+	for _, s := range p {
+		sum.total += s.total
+		sum.covered += s.covered
+	}
+	return sum
+}
+
+func readCoverProfile(filename string) (coverProfile, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseCoverProfile(bufio.NewScanner(f))
+}
+
+// parseCoverProfile parses `go test -coverprofile` output.
+// Blocks repeated across concatenated profiles are counted once, covered if covered in any of them.
+func parseCoverProfile(scanner *bufio.Scanner) (coverProfile, error) {
+	type block struct {
+		stmts   int
+		covered bool
+	}
+	blocks := make(map[string]block)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "mode:") {
+			continue
+		}
+		// file.go:startLine.startCol,endLine.endCol numStmts count
+		fields := strings.Fields(text)
+		if len(fields) != 3 || !strings.Contains(fields[0], ":") {
+			return nil, errors.Errorf("malformed cover profile line %d: %q", line, text)
+		}
+		stmts, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "malformed cover profile line %d", line)
+		}
+		count, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, errors.Wrapf(err, "malformed cover profile line %d", line)
+		}
+		b := blocks[fields[0]]
+		b.stmts = stmts
+		b.covered = b.covered || count > 0
+		blocks[fields[0]] = b
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	profile := make(coverProfile)
+	for pos, b := range blocks {
+		file := pos[:strings.LastIndex(pos, ":")]
+		pkg := path.Dir(file)
+		s := profile[pkg]
+		s.total += b.stmts
+		if b.covered {
+			s.covered += b.stmts
+		}
+		profile[pkg] = s
+	}
+	return profile, nil
+}
+
+func diffCoverage(base, head coverProfile) CoverageDiffResult {
+	result := CoverageDiffResult{
+		BaseTotal: base.total().percent(),
+		HeadTotal: head.total().percent(),
+	}
+	result.Delta = result.HeadTotal - result.BaseTotal
+	for pkg, h := range head {
+		d := PackageCoverageDiff{Package: pkg, Head: h.percent()}
+		if b, ok := base[pkg]; ok {
+			d.Base = b.percent()
+			d.Delta = d.Head - d.Base
+		} else {
+			d.New = true
+		}
+		result.Packages = append(result.Packages, d)
+	}
+	sort.Slice(result.Packages, func(i, j int) bool {
+		if result.Packages[i].Delta != result.Packages[j].Delta {
+			return result.Packages[i].Delta < result.Packages[j].Delta
+		}
+		return result.Packages[i].Package < result.Packages[j].Package
+	})
+	return result
+}
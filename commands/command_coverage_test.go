@@ -0,0 +1,192 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func parseProfileString(t *testing.T, profile string) coverProfile {
+	t.Helper()
+	p, err := parseCoverProfile(bufio.NewScanner(strings.NewReader(profile)))
+	if err != nil {
+		t.Fatalf("parseCoverProfile() error = %v", err)
+	}
+	return p
+}
+
+func TestParseCoverProfile(t *testing.T) {
+	profile := `mode: set
+example.com/a/a.go:3.10,5.2 2 1
+example.com/a/a.go:6.10,8.2 3 0
+example.com/b/b.go:3.10,5.2 4 0
+mode: set
+example.com/a/a.go:6.10,8.2 3 1
+example.com/b/b.go:3.10,5.2 4 0
+`
+	got := parseProfileString(t, profile)
+	want := coverProfile{
+		"example.com/a": {total: 5, covered: 5},
+		"example.com/b": {total: 4, covered: 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseCoverProfile() = %+v, want %+v", got, want)
+	}
+	if total := got.total(); total != (coverStatements{total: 9, covered: 5}) {
+		t.Errorf("total() = %+v", total)
+	}
+}
+
+func TestParseCoverProfileMalformed(t *testing.T) {
+	tests := []string{
+		"mode: set\nexample.com/a/a.go:3.10,5.2 2\n",
+		"mode: set\nexample.com/a/a.go 2 1\n",
+		"mode: set\nexample.com/a/a.go:3.10,5.2 two 1\n",
+		"mode: set\nexample.com/a/a.go:3.10,5.2 2 one\n",
+	}
+	for _, profile := range tests {
+		if _, err := parseCoverProfile(bufio.NewScanner(strings.NewReader(profile))); err == nil {
+			t.Errorf("parseCoverProfile(%q) expected an error", profile)
+		}
+	}
+}
+
+func TestDiffCoverage(t *testing.T) {
+	base := parseProfileString(t, `mode: set
+example.com/a/a.go:1.1,2.1 2 1
+example.com/a/a.go:3.1,4.1 2 1
+example.com/b/b.go:1.1,2.1 4 0
+example.com/gone/g.go:1.1,2.1 4 1
+`)
+	head := parseProfileString(t, `mode: set
+example.com/a/a.go:1.1,2.1 2 1
+example.com/a/a.go:3.1,4.1 2 0
+example.com/b/b.go:1.1,2.1 4 1
+example.com/c/c.go:1.1,2.1 4 0
+`)
+	got := diffCoverage(base, head)
+	// removed packages still count into the base total
+	wantBase := float64(8) / 12 * 100
+	if got.BaseTotal != wantBase || got.HeadTotal != 50 || got.Delta != 50-wantBase {
+		t.Errorf("totals = %v -> %v (%v), want %v -> 50", got.BaseTotal, got.HeadTotal, got.Delta, wantBase)
+	}
+	want := []PackageCoverageDiff{
+		{Package: "example.com/a", Base: 100, Head: 50, Delta: -50},
+		{Package: "example.com/c", New: true},
+		{Package: "example.com/b", Base: 0, Head: 100, Delta: 100},
+	}
+	if !reflect.DeepEqual(got.Packages, want) {
+		t.Errorf("Packages = %+v, want %+v", got.Packages, want)
+	}
+	if dropped := got.Dropped(); len(dropped) != 1 || dropped[0].Package != "example.com/a" {
+		t.Errorf("Dropped() = %+v", dropped)
+	}
+}
+
+func TestCoverageDiffMaxDrop(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coverage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 4 of 8 statements covered on base, 3 of 8 on head: a drop of exactly 12.5
+	base := filepath.Join(dir, "base.out")
+	head := filepath.Join(dir, "head.out")
+	write := func(path, contents string) {
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(base, "mode: set\nexample.com/a/a.go:1.1,2.1 4 1\nexample.com/a/a.go:3.1,4.1 4 0\n")
+	write(head, "mode: set\nexample.com/a/a.go:1.1,2.1 3 1\nexample.com/a/a.go:3.1,4.1 5 0\n")
+
+	tests := []struct {
+		name    string
+		cfg     CoverageDiffConfig
+		wantErr bool
+	}{
+		{"gate disabled", CoverageDiffConfig{}, false},
+		{"drop within allowance", CoverageDiffConfig{FailOnDrop: true, MaxDrop: 13}, false},
+		{"drop equal to allowance", CoverageDiffConfig{FailOnDrop: true, MaxDrop: 12.5}, false},
+		{"drop beyond allowance", CoverageDiffConfig{FailOnDrop: true, MaxDrop: 12.4}, true},
+		{"no drop allowed", CoverageDiffConfig{FailOnDrop: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := CoverageDiff(tt.cfg, base, head)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CoverageDiff() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if result.Delta != -12.5 {
+				t.Errorf("Delta = %v, want -12.5", result.Delta)
+			}
+		})
+	}
+}
+
+func TestCoverageHTML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coverage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	profile := filepath.Join(dir, "cover.out")
+	contents := "mode: set\ngithub.com/zolia/go-ci/commands/command_coverage.go:20.1,30.2 3 1\n"
+	if err := ioutil.WriteFile(profile, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "html", "coverage.html")
+	if err := CoverageHTML(profile, out); err != nil {
+		t.Fatalf("CoverageHTML() error = %v", err)
+	}
+	html, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(html), "command_coverage.go") {
+		t.Errorf("report does not mention the covered file")
+	}
+}
+
+func TestCoverageHTMLBadProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coverage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	empty := filepath.Join(dir, "empty.out")
+	if err := ioutil.WriteFile(empty, []byte("mode: set\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		profile string
+		wantErr string
+	}{
+		{"missing", filepath.Join(dir, "missing.out"), "does not exist"},
+		{"empty", empty, "is empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CoverageHTML(tt.profile, filepath.Join(dir, "out.html"))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CoverageHTML() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/magefile/mage/sh"
+	"github.com/pkg/errors"
+)
+
+// FuzzConfig describes a GoFuzz run
+type FuzzConfig struct {
+	// Packages to look for fuzz targets in, defaults to "./..."
+	Packages []string
+	// Targets limits the run to these fuzz targets, given as "FuzzName" or "import/path.FuzzName"
+	Targets []string
+	// Budget is the total fuzzing time, divided evenly across the targets
+	Budget time.Duration
+}
+
+// fuzzTarget is a fuzz function of a package
+type fuzzTarget struct {
+	Package string
+	Dir     string
+	Name    string
+}
+
+func (t fuzzTarget) String() string {
+	return t.Package + "." + t.Name
+}
+
+const minFuzzTime = time.Second
+
+var (
+	fuzzNameRegex     = regexp.MustCompile(`^Fuzz\w*$`)
+	failingInputRegex = regexp.MustCompile(`Failing input written to (\S+)`)
+)
+
+// GoFuzz discovers the fuzz targets of the packages and fuzzes them one after another, splitting the budget between them.
+// Failing inputs saved under testdata/fuzz are listed in the returned error.
+//
+// Example:
+//     commands.GoFuzz(commands.FuzzConfig{Packages: []string{"./parser/..."}, Budget: 10 * time.Minute})
+func GoFuzz(cfg FuzzConfig) error {
+	if cfg.Budget <= 0 {
+		return errors.New("fuzz: budget is required")
+	}
+	targets, err := findFuzzTargets(cfg)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		fmt.Println("fuzz: no fuzz targets found")
+		return nil
+	}
+	fuzzTime := fuzzTimePerTarget(cfg.Budget, len(targets))
+
+	var failures []string
+	for i, target := range targets {
+		fmt.Printf("fuzz: [%d/%d] fuzzing %s for %s\n", i+1, len(targets), target, fuzzTime)
+		var out bytes.Buffer
+		_, err := sh.Exec(nil, io.MultiWriter(os.Stdout, &out), os.Stderr, "go", fuzzArgs(target, fuzzTime)...)
+		if err == nil {
+			continue
+		}
+		failure := fmt.Sprintf("%s: %s", target, err)
+		if input := failingInput(out.String()); input != "" {
+			input = filepath.Join(target.Dir, input)
+			fmt.Printf("fuzz: failing input of %s saved to %s\n", target, input)
+			failure = fmt.Sprintf("%s: failing input %s", target, input)
+		}
+		failures = append(failures, failure)
+	}
+	if len(failures) != 0 {
+		return errors.Errorf("fuzz: %d of %d target(s) failed:\n%s", len(failures), len(targets), strings.Join(failures, "\n"))
+	}
+	fmt.Printf("fuzz: all %d target(s) passed\n", len(targets))
+	return nil
+}
+
+func findFuzzTargets(cfg FuzzConfig) ([]fuzzTarget, error) {
+	patterns := cfg.Packages
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	out, err := sh.Output("go", append([]string{"list", "-f", "{{.ImportPath}} {{.Dir}}"}, patterns...)...)
+	if err != nil {
+		fmt.Printf("fuzz: go list crashed: %s\n", err)
+		return nil, err
+	}
+
+	var targets []fuzzTarget
+	for _, line := range nonEmpty(strings.Split(out, "\n")) {
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			return nil, errors.Errorf("fuzz: unexpected go list output %q", line)
+		}
+		list, err := sh.Output("go", "test", "-list", "^Fuzz", fields[0])
+		if err != nil {
+			fmt.Printf("fuzz: could not list fuzz targets of %s: %s\n", fields[0], err)
+			return nil, err
+		}
+		for _, name := range parseFuzzList(list) {
+			target := fuzzTarget{Package: fields[0], Dir: fields[1], Name: name}
+			if fuzzTargetSelected(cfg.Targets, target) {
+				targets = append(targets, target)
+			}
+		}
+	}
+	return targets, nil
+}
+
+// parseFuzzList returns the fuzz target names from `go test -list ^Fuzz` output
+func parseFuzzList(out string) []string {
+	var names []string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if fuzzNameRegex.MatchString(line) {
+			names = append(names, line)
+		}
+	}
+	return names
+}
+
+func fuzzTargetSelected(selected []string, target fuzzTarget) bool {
+	if len(selected) == 0 {
+		return true
+	}
+	for _, s := range selected {
+		if s == target.Name || s == target.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// fuzzTimePerTarget divides the budget evenly, giving every target at least a second
+func fuzzTimePerTarget(budget time.Duration, targets int) time.Duration {
+	perTarget := (budget / time.Duration(targets)).Truncate(time.Second)
+	if perTarget < minFuzzTime {
+		return minFuzzTime
+	}
+	return perTarget
+}
+
+// fuzzArgs fuzzes a single target, -run matches no test so unit tests do not run alongside
+func fuzzArgs(target fuzzTarget, fuzzTime time.Duration) []string {
+	return []string{"test", "-run=^$", "-fuzz=^" + target.Name + "$", "-fuzztime=" + fuzzTime.String(), target.Package}
+}
+
+// failingInput returns the corpus entry path, relative to the package directory, reported by a failed fuzz run
+func failingInput(out string) string {
+	match := failingInputRegex.FindStringSubmatch(out)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
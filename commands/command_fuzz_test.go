@@ -0,0 +1,80 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseFuzzList(t *testing.T) {
+	out := "FuzzParse\nFuzzDecode\nok  \texample.com/p\t0.003s\n"
+	want := []string{"FuzzParse", "FuzzDecode"}
+	if got := parseFuzzList(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseFuzzList() = %v, want %v", got, want)
+	}
+	if got := parseFuzzList("ok  \texample.com/p\t0.003s\n"); got != nil {
+		t.Errorf("parseFuzzList() = %v, want none", got)
+	}
+}
+
+func TestFuzzTimePerTarget(t *testing.T) {
+	tests := []struct {
+		budget  time.Duration
+		targets int
+		want    time.Duration
+	}{
+		{time.Minute, 1, time.Minute},
+		{time.Minute, 4, 15 * time.Second},
+		{10 * time.Second, 3, 3 * time.Second},
+		{time.Second, 5, time.Second},
+	}
+	for _, tt := range tests {
+		if got := fuzzTimePerTarget(tt.budget, tt.targets); got != tt.want {
+			t.Errorf("fuzzTimePerTarget(%s, %d) = %s, want %s", tt.budget, tt.targets, got, tt.want)
+		}
+	}
+}
+
+func TestFuzzTargetSelected(t *testing.T) {
+	target := fuzzTarget{Package: "example.com/p", Name: "FuzzParse"}
+	tests := []struct {
+		selected []string
+		want     bool
+	}{
+		{nil, true},
+		{[]string{"FuzzParse"}, true},
+		{[]string{"example.com/p.FuzzParse"}, true},
+		{[]string{"FuzzDecode", "example.com/other.FuzzParse"}, false},
+	}
+	for _, tt := range tests {
+		if got := fuzzTargetSelected(tt.selected, target); got != tt.want {
+			t.Errorf("fuzzTargetSelected(%v) = %v, want %v", tt.selected, got, tt.want)
+		}
+	}
+}
+
+func TestFuzzArgs(t *testing.T) {
+	got := fuzzArgs(fuzzTarget{Package: "example.com/p", Name: "FuzzParse"}, 30*time.Second)
+	want := []string{"test", "-run=^$", "-fuzz=^FuzzParse$", "-fuzztime=30s", "example.com/p"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fuzzArgs() = %v, want %v", got, want)
+	}
+}
+
+func TestFailingInput(t *testing.T) {
+	out := "--- FAIL: FuzzParse (0.01s)\n    Failing input written to testdata/fuzz/FuzzParse/1de061fa\n    To re-run:\n"
+	if got := failingInput(out); got != "testdata/fuzz/FuzzParse/1de061fa" {
+		t.Errorf("failingInput() = %q", got)
+	}
+	if got := failingInput("FAIL\texample.com/p [build failed]"); got != "" {
+		t.Errorf("failingInput() = %q, want empty", got)
+	}
+}
@@ -0,0 +1,212 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/zolia/go-ci/util"
+)
+
+const defaultCycloThreshold = 15
+
+// CycloConfig configures GoCyclo
+type CycloConfig struct {
+	// Threshold is the highest allowed complexity, defaults to 15
+	Threshold int
+	// AllowList grandfathers existing offenders, as "importpath.Func" or "importpath.Type.Method",
+	// e.g. "github.com/zolia/go-ci/commands.GoLint"
+	AllowList []string
+	// TopN prints the N most complex functions even when the check passes
+	TopN int
+	// Excludes are directories to skip, in addition to util.GoLintExcludes()
+	Excludes []string
+	// SkipTests skips _test.go files
+	SkipTests bool
+	// SkipGenerated skips generated files
+	SkipGenerated bool
+}
+
+// FuncComplexity is the cyclomatic complexity of a single function
+type FuncComplexity struct {
+	// Name is "importpath.Func" or "importpath.Type.Method"
+	Name       string
+	Complexity int
+	Position   token.Position
+}
+
+func (f FuncComplexity) String() string {
+	return fmt.Sprintf("%d %s %s", f.Complexity, f.Name, f.Position)
+}
+
+// GoCyclo fails when any function of the project exceeds the cyclomatic complexity threshold.
+// Complexity is 1 plus the number of if, for, case, && and || in the function, function literals included.
+//
+// Example:
+//     commands.GoCyclo(commands.CycloConfig{Threshold: 20, AllowList: []string{"github.com/zolia/go-ci/commands.GoLint"}, TopN: 10})
+func GoCyclo(cfg CycloConfig) error {
+	threshold := cfg.Threshold
+	if threshold == 0 {
+		threshold = defaultCycloThreshold
+	}
+	var allExcludes []string
+	allExcludes = append(allExcludes, cfg.Excludes...)
+	allExcludes = append(allExcludes, util.GoLintExcludes()...)
+	files, err := util.GetProjectGoFiles(allExcludes)
+	if err != nil {
+		fmt.Printf("gocyclo: error listing files: %s\n", err)
+		return err
+	}
+
+	modulePath := util.GetModulePath()
+	var funcs []FuncComplexity
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if cfg.SkipTests && strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, parser.ParseComments)
+		if err != nil {
+			fmt.Printf("gocyclo: could not parse %s: %s\n", file, err)
+			return err
+		}
+		if cfg.SkipGenerated && isGenerated(f) {
+			continue
+		}
+		funcs = append(funcs, fileComplexities(fset, packagePath(modulePath, file), f)...)
+	}
+	sort.SliceStable(funcs, func(i, j int) bool {
+		return funcs[i].Complexity > funcs[j].Complexity
+	})
+
+	allowed := make(map[string]bool)
+	for _, name := range cfg.AllowList {
+		allowed[name] = true
+	}
+	var offenders []string
+	for _, f := range funcs {
+		if f.Complexity > threshold && !allowed[f.Name] {
+			offenders = append(offenders, f.String())
+		}
+	}
+
+	if cfg.TopN > 0 && len(funcs) != 0 {
+		top := funcs
+		if len(top) > cfg.TopN {
+			top = top[:cfg.TopN]
+		}
+		fmt.Printf("gocyclo: %d most complex functions:\n", len(top))
+		for _, f := range top {
+			fmt.Println(f)
+		}
+	}
+	if len(offenders) != 0 {
+		fmt.Printf("gocyclo: the following functions exceed complexity %d:\n", threshold)
+		fmt.Println(strings.Join(offenders, "\n"))
+		return errors.Errorf("gocyclo: %d function(s) exceed complexity %d", len(offenders), threshold)
+	}
+	fmt.Printf("gocyclo: all functions are within complexity %d\n", threshold)
+	return nil
+}
+
+func isGenerated(f *ast.File) bool {
+	for _, group := range f.Comments {
+		for _, c := range group.List {
+			if generatedRegex.MatchString(c.Text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// packagePath returns the import path of the package the file belongs to,
+// or its directory when not in module mode, so functions of different main packages do not collide
+func packagePath(modulePath, file string) string {
+	dir := filepath.ToSlash(filepath.Dir(file))
+	if modulePath == "" {
+		return dir
+	}
+	return path.Join(modulePath, dir)
+}
+
+func fileComplexities(fset *token.FileSet, pkgPath string, f *ast.File) []FuncComplexity {
+	var funcs []FuncComplexity
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		funcs = append(funcs, FuncComplexity{
+			Name:       funcName(pkgPath, fn),
+			Complexity: complexity(fn),
+			Position:   fset.Position(fn.Pos()),
+		})
+	}
+	return funcs
+}
+
+func funcName(pkg string, fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return pkg + "." + fn.Name.Name
+	}
+	typ := fn.Recv.List[0].Type
+	for {
+		switch t := typ.(type) {
+		case *ast.StarExpr:
+			typ = t.X
+			continue
+		case *ast.IndexExpr:
+			// generic receiver with one type parameter, T[K]
+			typ = t.X
+			continue
+		case *ast.IndexListExpr:
+			// generic receiver with several type parameters, T[K, V]
+			typ = t.X
+			continue
+		case *ast.Ident:
+			return pkg + "." + t.Name + "." + fn.Name.Name
+		}
+		return pkg + "." + fn.Name.Name
+	}
+}
+
+// complexity counts the decision points of the function body
+func complexity(fn ast.Node) int {
+	c := 1
+	ast.Inspect(fn, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt:
+			c++
+		case *ast.CaseClause:
+			if n.List != nil {
+				c++
+			}
+		case *ast.CommClause:
+			if n.Comm != nil {
+				c++
+			}
+		case *ast.BinaryExpr:
+			if n.Op == token.LAND || n.Op == token.LOR {
+				c++
+			}
+		}
+		return true
+	})
+	return c
+}
@@ -0,0 +1,181 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+const cycloSource = `package sample
+
+type T struct{}
+
+func Straight() int {
+	return 1
+}
+
+func If(a int) int {
+	if a > 0 {
+		return 1
+	} else if a < 0 {
+		return -1
+	}
+	return 0
+}
+
+func Loops(xs []int) int {
+	n := 0
+	for i := 0; i < len(xs); i++ {
+		n++
+	}
+	for range xs {
+		n++
+	}
+	return n
+}
+
+func Switch(a int) string {
+	switch a {
+	case 1, 2:
+		return "small"
+	case 3:
+		return "three"
+	default:
+		return "other"
+	}
+}
+
+func TypeSwitch(v interface{}) string {
+	switch v.(type) {
+	case int:
+		return "int"
+	case string:
+		return "string"
+	}
+	return ""
+}
+
+func Select(a, b chan int) int {
+	select {
+	case x := <-a:
+		return x
+	case b <- 1:
+		return 0
+	default:
+		return -1
+	}
+}
+
+func Logic(a, b, c bool) bool {
+	return a && b || c
+}
+
+func (t T) Value(a bool) bool {
+	if a && true {
+		return true
+	}
+	return false
+}
+
+func (t *T) Pointer() {}
+
+func (*T) Unnamed() {}
+
+type G[K comparable, V any] struct{}
+
+func (g *G[K, V]) Get(k K) bool {
+	return g != nil
+}
+
+type L[E any] []E
+
+func (l L[E]) Len() int {
+	if l == nil {
+		return 0
+	}
+	return len(l)
+}
+`
+
+func TestFileComplexities(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "sample.go", cycloSource, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{
+		"example.com/sample.Straight":   1,
+		"example.com/sample.If":         3,
+		"example.com/sample.Loops":      3,
+		"example.com/sample.Switch":     3,
+		"example.com/sample.TypeSwitch": 3,
+		"example.com/sample.Select":     3,
+		"example.com/sample.Logic":      3,
+		"example.com/sample.T.Value":    3,
+		"example.com/sample.T.Pointer":  1,
+		"example.com/sample.T.Unnamed":  1,
+		"example.com/sample.G.Get":      1,
+		"example.com/sample.L.Len":      2,
+	}
+	funcs := fileComplexities(fset, "example.com/sample", f)
+	if len(funcs) != len(want) {
+		t.Fatalf("fileComplexities() returned %d functions, want %d: %v", len(funcs), len(want), funcs)
+	}
+	for _, fn := range funcs {
+		c, ok := want[fn.Name]
+		if !ok {
+			t.Errorf("unexpected function %s", fn.Name)
+			continue
+		}
+		if fn.Complexity != c {
+			t.Errorf("complexity of %s = %d, want %d", fn.Name, fn.Complexity, c)
+		}
+	}
+}
+
+func TestGoCycloAllowListByImportPath(t *testing.T) {
+	const complexRun = "package main\n\nfunc run(a, b, c bool) bool {\n\treturn a && b || c\n}\n\nfunc main() { run(true, true, true) }\n"
+	files := map[string]string{
+		"cmd/a/main.go": complexRun,
+		"cmd/b/main.go": complexRun,
+	}
+	inFixtureModule(t, files, func(dir string) {
+		cfg := CycloConfig{Threshold: 2, AllowList: []string{"example.com/fixture/cmd/a.run"}}
+		if err := GoCyclo(cfg); err == nil || !strings.Contains(err.Error(), "1 function(s)") {
+			t.Errorf("GoCyclo() error = %v, want only cmd/b.run reported", err)
+		}
+		cfg.AllowList = append(cfg.AllowList, "example.com/fixture/cmd/b.run")
+		if err := GoCyclo(cfg); err != nil {
+			t.Errorf("GoCyclo() with both packages allowed error = %v", err)
+		}
+	})
+}
+
+func TestIsGenerated(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"// Code generated by mockgen. DO NOT EDIT.\n\npackage a\n", true},
+		{"package a\n\n// Code generated by hand.\n", false},
+		{"package a\n", false},
+	}
+	for _, tt := range tests {
+		f, err := parser.ParseFile(token.NewFileSet(), "a.go", tt.src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := isGenerated(f); got != tt.want {
+			t.Errorf("isGenerated(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
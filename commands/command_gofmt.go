@@ -101,3 +101,69 @@ func GoFmtD(dir string, excludes ...string) error {
 	fmt.Println("gofmt: all files are OK!")
 	return nil
 }
+
+// GoFmtFixConfig configures GoFmtFixWith
+type GoFmtFixConfig struct {
+	// Simplify applies gofmt -s simplifications
+	Simplify bool
+}
+
+// GoFmtFix formats the project files in place and returns the list of files it modified.
+//
+// When running in CI (CI=true) it refuses to write and only checks the formatting,
+// so pipelines never silently mutate the tree.
+//
+// Example:
+//     commands.GoFmtFix(".", "docs")
+func GoFmtFix(dir string, excludes ...string) ([]string, error) {
+	return GoFmtFixWith(GoFmtFixConfig{}, dir, excludes...)
+}
+
+// GoFmtFixWith formats the project files in place using the given config and returns the list of files it modified.
+func GoFmtFixWith(cfg GoFmtFixConfig, dir string, excludes ...string) ([]string, error) {
+	goFmtBin, err := util.GetGoBinaryPath("gofmt")
+	if err != nil {
+		fmt.Println("Tool 'gofmt' not found")
+		return nil, err
+	}
+	var allExcludes []string
+	allExcludes = append(allExcludes, excludes...)
+	allExcludes = append(allExcludes, util.GoLintExcludes()...)
+	files, err := util.GetProjectGoFiles(allExcludes)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		fmt.Println("gofmt: no files to format")
+		return nil, nil
+	}
+
+	var flags []string
+	if cfg.Simplify {
+		flags = append(flags, "-s")
+	}
+	out, err := sh.Output(goFmtBin, append(append([]string{"-e", "-l"}, flags...), files...)...)
+	if err != nil {
+		fmt.Printf("gofmt: error executing %s\n", err)
+		return nil, err
+	}
+	unformatted := nonEmpty(strings.Split(out, "\n"))
+	if len(unformatted) == 0 {
+		fmt.Println("gofmt: all files are OK!")
+		return nil, nil
+	}
+	if util.IsCI() {
+		fmt.Println("gofmt: running in CI, refusing to fix the following files:")
+		fmt.Println(out)
+		return nil, errors.New("gofmt: not all formatting follow the gofmt format")
+	}
+
+	_, err = sh.Output(goFmtBin, append(append([]string{"-w"}, flags...), unformatted...)...)
+	if err != nil {
+		fmt.Printf("gofmt: error executing %s\n", err)
+		return nil, err
+	}
+	fmt.Println("gofmt: formatted the following files:")
+	fmt.Println(out)
+	return unformatted, nil
+}
@@ -0,0 +1,41 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGoFmtFixRootFiles(t *testing.T) {
+	requireTool(t, "gofmt")
+	files := map[string]string{
+		"main.go":  "package main\nfunc main() {\n}\n",
+		"sub/a.go": "package sub\nvar A = 1\n",
+	}
+	inFixtureModule(t, files, func(dir string) {
+		fixed, err := GoFmtFix(".")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"main.go", filepath.Join("sub", "a.go")}
+		if !reflect.DeepEqual(fixed, want) {
+			t.Errorf("GoFmtFix() = %q, want %q", fixed, want)
+		}
+		src, err := ioutil.ReadFile("main.go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(src) != "package main\n\nfunc main() {\n}\n" {
+			t.Errorf("main.go was not formatted:\n%s", src)
+		}
+	})
+}
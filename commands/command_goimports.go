@@ -25,26 +25,16 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/magefile/mage/mg"
 	"github.com/magefile/mage/sh"
-	"github.com/zolia/go-ci/shell"
+
 	"github.com/zolia/go-ci/util"
 )
 
-// Fetches the 	goimports binary
+// GetImports installs the goimports binary if it is missing
 func GetImports() error {
-	path, _ := util.GetGoBinaryPath("goimports")
-	if path != "" {
-		fmt.Println("Tool 'goimports' already installed")
-		return nil
-	}
-
-	cmd := shell.NewCmd("/usr/local/go/bin/go get -u -x -v golang.org/x/tools/cmd/goimports")
-	out, err := cmd.Output()
-	fmt.Printf("go get goimports output: %s\n", out)
-	// err := sh.RunV("go", "get", "-v", "-x", "-u", "golang.org/x/tools/cmd/goimports")
+	_, err := EnsureTool(goImportsImportPath, GoImportsVersion)
 	if err != nil {
-		fmt.Printf("Could not go get goimports: %s\n", err)
+		fmt.Printf("Could not install goimports: %s\n", err)
 		return err
 	}
 	return nil
@@ -52,9 +42,7 @@ func GetImports() error {
 
 // GoImports checks for issues with go imports
 func GoImports(pathToCheck string, excludes ...string) error {
-	mg.Deps(GetImports)
-
-	goimportsBinaryPath, err := util.GetGoBinaryPath("goimports")
+	goimportsBinaryPath, err := EnsureTool(goImportsImportPath, GoImportsVersion)
 	if err != nil {
 		fmt.Println("Tool 'goimports' not found")
 		return err
@@ -99,6 +87,13 @@ func GoImports(pathToCheck string, excludes ...string) error {
 	return nil
 }
 
+// GoImportsConfig configures the goimports check and fix
+type GoImportsConfig struct {
+	// LocalPrefix puts imports beginning with this string after 3rd-party packages (goimports -local).
+	// Defaults to the module path.
+	LocalPrefix string
+}
+
 // GoImportsD checks for issues with go imports.
 //
 // Instead of packages, it operates on directories, thus it is compatible with gomodules outside GOPATH.
@@ -106,8 +101,12 @@ func GoImports(pathToCheck string, excludes ...string) error {
 // Example:
 //     commands.GoImportsD(".", "docs")
 func GoImportsD(dir string, excludes ...string) error {
-	mg.Deps(GetImports)
-	goimportsBin, err := util.GetGoBinaryPath("goimports")
+	return GoImportsDWith(GoImportsConfig{}, dir, excludes...)
+}
+
+// GoImportsDWith checks for issues with go imports using the given config.
+func GoImportsDWith(cfg GoImportsConfig, dir string, excludes ...string) error {
+	goimportsBin, err := EnsureTool(goImportsImportPath, GoImportsVersion)
 	if err != nil {
 		fmt.Println("Tool 'goimports' not found")
 		return err
@@ -119,7 +118,8 @@ func GoImportsD(dir string, excludes ...string) error {
 	if err != nil {
 		return err
 	}
-	out, err := shell.NewCmd(goimportsBin + " -e -l -d " + strings.Join(dirs, " ")).Output()
+	args := append([]string{"-e", "-l", "-d"}, goImportsLocalArgs(cfg)...)
+	out, err := sh.Output(goimportsBin, append(args, dirs...)...)
 	if err != nil {
 		fmt.Printf("goimports: error executing %s\n", err)
 		return err
@@ -132,3 +132,74 @@ func GoImportsD(dir string, excludes ...string) error {
 	fmt.Println("goimports: all files are OK!")
 	return nil
 }
+
+// GoImportsFix fixes imports of the project files in place and returns the list of files it modified.
+//
+// When running in CI (CI=true) it refuses to write and only checks the imports,
+// so pipelines never silently mutate the tree.
+//
+// Example:
+//     commands.GoImportsFix(".", "docs")
+func GoImportsFix(dir string, excludes ...string) ([]string, error) {
+	return GoImportsFixWith(GoImportsConfig{}, dir, excludes...)
+}
+
+// GoImportsFixWith fixes imports of the project files in place using the given config and returns the list of files it modified.
+func GoImportsFixWith(cfg GoImportsConfig, dir string, excludes ...string) ([]string, error) {
+	goimportsBin, err := EnsureTool(goImportsImportPath, GoImportsVersion)
+	if err != nil {
+		fmt.Println("Tool 'goimports' not found")
+		return nil, err
+	}
+	var allExcludes []string
+	allExcludes = append(allExcludes, excludes...)
+	allExcludes = append(allExcludes, util.GoLintExcludes()...)
+	files, err := util.GetProjectGoFiles(allExcludes)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		fmt.Println("goimports: no files to fix")
+		return nil, nil
+	}
+
+	localArgs := goImportsLocalArgs(cfg)
+	args := append([]string{"-e", "-l"}, localArgs...)
+	out, err := sh.Output(goimportsBin, append(args, files...)...)
+	if err != nil {
+		fmt.Printf("goimports: error executing %s\n", err)
+		return nil, err
+	}
+	badFiles := nonEmpty(strings.Split(out, "\n"))
+	if len(badFiles) == 0 {
+		fmt.Println("goimports: all files are OK!")
+		return nil, nil
+	}
+	if util.IsCI() {
+		fmt.Println("goimports: running in CI, refusing to fix the following files:")
+		fmt.Println(out)
+		return nil, errors.New("goimports: not all imports follow the goimports format")
+	}
+
+	args = append([]string{"-w"}, localArgs...)
+	_, err = sh.Output(goimportsBin, append(args, badFiles...)...)
+	if err != nil {
+		fmt.Printf("goimports: error executing %s\n", err)
+		return nil, err
+	}
+	fmt.Println("goimports: fixed the following files:")
+	fmt.Println(out)
+	return badFiles, nil
+}
+
+// goImportsLocalArgs returns the -local flag for goimports, falling back to the module path when no prefix is configured
+func goImportsLocalArgs(cfg GoImportsConfig) []string {
+	prefix := cfg.LocalPrefix
+	if prefix == "" {
+		prefix = util.GetModulePath()
+	}
+	if prefix == "" {
+		return nil
+	}
+	return []string{"-local", prefix}
+}
@@ -0,0 +1,160 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/zolia/go-ci/util"
+)
+
+// inFixtureModule creates a module with the given files in a temp dir and runs the test from it
+func inFixtureModule(t *testing.T, files map[string]string, test func(dir string)) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files["go.mod"] = "module example.com/fixture\n\ngo 1.14\n"
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	ci, hadCI := os.LookupEnv("CI")
+	os.Unsetenv("CI")
+	if hadCI {
+		defer os.Setenv("CI", ci)
+	}
+	test(dir)
+}
+
+func requireTool(t *testing.T, name string) {
+	t.Helper()
+	if _, err := util.GetGoBinaryPath(name); err != nil {
+		t.Skipf("%s is not installed", name)
+	}
+}
+
+const (
+	misgroupedImports = `package p
+
+import (
+	"example.com/fixture/q"
+	"fmt"
+)
+
+func P() { fmt.Println(q.Q) }
+`
+	groupedImports = `package p
+
+import (
+	"fmt"
+
+	"example.com/fixture/q"
+)
+
+func P() { fmt.Println(q.Q) }
+`
+	missingImport = `package q
+
+// Q is exported
+var Q = strings.ToUpper("q")
+`
+)
+
+func TestGoImportsFixture(t *testing.T) {
+	requireTool(t, "goimports")
+	files := map[string]string{
+		"p/p.go": misgroupedImports,
+		"q/q.go": missingImport,
+		"r/r.go": groupedImports,
+	}
+	inFixtureModule(t, files, func(dir string) {
+		if err := GoImportsD("."); err == nil {
+			t.Fatal("GoImportsD() expected an error for misformatted fixtures")
+		}
+
+		fixed, err := GoImportsFix(".")
+		if err != nil {
+			t.Fatalf("GoImportsFix() error = %v", err)
+		}
+		want := []string{"p/p.go", "q/q.go"}
+		if !reflect.DeepEqual(fixed, want) {
+			t.Errorf("GoImportsFix() = %v, want %v", fixed, want)
+		}
+		contents, err := ioutil.ReadFile(filepath.Join(dir, "p/p.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(contents) != groupedImports {
+			t.Errorf("p/p.go was not regrouped with the module path as local prefix:\n%s", contents)
+		}
+
+		if err := GoImportsD("."); err != nil {
+			t.Errorf("GoImportsD() after fix error = %v", err)
+		}
+	})
+}
+
+func TestGoImportsLocalPrefix(t *testing.T) {
+	requireTool(t, "goimports")
+	files := map[string]string{"r/r.go": groupedImports}
+	inFixtureModule(t, files, func(dir string) {
+		if err := GoImportsDWith(GoImportsConfig{LocalPrefix: "example.com/fixture"}, "."); err != nil {
+			t.Errorf("GoImportsDWith() error = %v", err)
+		}
+
+		want := []string{"-local", "example.com/fixture"}
+		if got := goImportsLocalArgs(GoImportsConfig{}); !reflect.DeepEqual(got, want) {
+			t.Errorf("goImportsLocalArgs() default = %v, want %v", got, want)
+		}
+		want = []string{"-local", "example.com/other"}
+		if got := goImportsLocalArgs(GoImportsConfig{LocalPrefix: "example.com/other"}); !reflect.DeepEqual(got, want) {
+			t.Errorf("goImportsLocalArgs() = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestGoImportsFixRefusesInCI(t *testing.T) {
+	requireTool(t, "goimports")
+	files := map[string]string{"p/p.go": misgroupedImports, "q/q.go": "package q\n\n// Q is exported\nvar Q = 1\n"}
+	inFixtureModule(t, files, func(dir string) {
+		os.Setenv("CI", "true")
+		defer os.Unsetenv("CI")
+		if _, err := GoImportsFix("."); err == nil {
+			t.Error("GoImportsFix() expected an error in CI")
+		}
+		contents, err := ioutil.ReadFile(filepath.Join(dir, "p/p.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(contents) != misgroupedImports {
+			t.Error("GoImportsFix() modified files in CI")
+		}
+	})
+}
@@ -0,0 +1,148 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/magefile/mage/sh"
+	"github.com/pkg/errors"
+
+	"github.com/zolia/go-ci/util"
+)
+
+const golangciLintImportPath = "github.com/golangci/golangci-lint/cmd/golangci-lint"
+
+// GolangciLintVersion is the default pinned golangci-lint version
+var GolangciLintVersion = "v1.59.1"
+
+// LintConfig configures GolangciLint
+type LintConfig struct {
+	// Version of golangci-lint to install when missing, defaults to GolangciLintVersion
+	Version string
+	// ConfigPath points to the golangci-lint config file, by default the tool looks it up itself
+	ConfigPath string
+	// Timeout of the whole run, the tool default is used when zero
+	Timeout time.Duration
+	// NewFromRev reports only issues introduced since the given git revision
+	NewFromRev string
+	// Excludes are directories to skip, in addition to util.GoLintExcludes()
+	Excludes []string
+	// OutputFormat is passed to --out-format, e.g. "github-actions" or "junit-xml"
+	OutputFormat string
+	// OutputFile, when set, writes OutputFormat to this file while the console keeps the human readable output.
+	// It requires OutputFormat.
+	OutputFile string
+}
+
+// GolangciLint runs golangci-lint over the project directories
+//
+// Example:
+//     commands.GolangciLint(commands.LintConfig{Timeout: 5 * time.Minute, NewFromRev: "origin/master"})
+func GolangciLint(cfg LintConfig) error {
+	if cfg.OutputFile != "" && cfg.OutputFormat == "" {
+		return errors.New("golangci-lint: output file requires an output format")
+	}
+	version := cfg.Version
+	if version == "" {
+		version = GolangciLintVersion
+	}
+	lintBin, err := EnsureTool(golangciLintImportPath, version)
+	if err != nil {
+		fmt.Println("golangci-lint: tool not found")
+		return err
+	}
+
+	var allExcludes []string
+	allExcludes = append(allExcludes, cfg.Excludes...)
+	allExcludes = append(allExcludes, util.GoLintExcludes()...)
+	dirs, err := util.GetProjectDirectoriesWithRoot(allExcludes)
+	if err != nil {
+		fmt.Printf("golangci-lint: could not list directories: %s\n", err)
+		return err
+	}
+	dirs, err = goDirs(dirs)
+	if err != nil {
+		return err
+	}
+	if len(dirs) == 0 {
+		fmt.Println("golangci-lint: no directories to lint")
+		return nil
+	}
+
+	_, err = sh.Exec(nil, os.Stdout, os.Stderr, lintBin, golangciLintArgs(cfg, dirs)...)
+	switch sh.ExitStatus(err) {
+	case 0:
+		fmt.Println("golangci-lint: no linting errors")
+		return nil
+	case 1:
+		return errors.New("golangci-lint: linting failed")
+	default:
+		fmt.Printf("golangci-lint: error executing %s\n", err)
+		return err
+	}
+}
+
+func golangciLintArgs(cfg LintConfig, dirs []string) []string {
+	args := []string{"run"}
+	if cfg.ConfigPath != "" {
+		args = append(args, "--config", cfg.ConfigPath)
+	}
+	if cfg.Timeout != 0 {
+		args = append(args, "--timeout", cfg.Timeout.String())
+	}
+	if cfg.NewFromRev != "" {
+		args = append(args, "--new-from-rev", cfg.NewFromRev)
+	}
+	if cfg.OutputFormat != "" {
+		format := cfg.OutputFormat
+		if cfg.OutputFile != "" {
+			format = "colored-line-number," + format + ":" + cfg.OutputFile
+		}
+		args = append(args, "--out-format", format)
+	}
+	return append(args, dirs...)
+}
+
+// goDirs returns the directories containing go files, prefixed with ./ so go tools treat them as paths.
+// Like the go tool itself, it skips testdata directories.
+func goDirs(dirs []string) ([]string, error) {
+	result := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		if isTestdata(dir) {
+			continue
+		}
+		files, err := util.GetGoFiles([]string{dir})
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			continue
+		}
+		if dir == "." {
+			result = append(result, dir)
+		} else {
+			result = append(result, "./"+dir)
+		}
+	}
+	return result, nil
+}
+
+func isTestdata(dir string) bool {
+	for _, elem := range strings.Split(filepath.ToSlash(dir), "/") {
+		if elem == "testdata" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zolia/go-ci/util"
+)
+
+func TestGolangciLintArgs(t *testing.T) {
+	dirs := []string{"./a", "./b/c"}
+	tests := []struct {
+		name string
+		cfg  LintConfig
+		want []string
+	}{
+		{
+			name: "defaults",
+			cfg:  LintConfig{},
+			want: []string{"run", "./a", "./b/c"},
+		},
+		{
+			name: "config",
+			cfg:  LintConfig{ConfigPath: ".golangci.yml"},
+			want: []string{"run", "--config", ".golangci.yml", "./a", "./b/c"},
+		},
+		{
+			name: "timeout",
+			cfg:  LintConfig{Timeout: 90 * time.Second},
+			want: []string{"run", "--timeout", "1m30s", "./a", "./b/c"},
+		},
+		{
+			name: "new from rev",
+			cfg:  LintConfig{NewFromRev: "origin/master"},
+			want: []string{"run", "--new-from-rev", "origin/master", "./a", "./b/c"},
+		},
+		{
+			name: "format",
+			cfg:  LintConfig{OutputFormat: "github-actions"},
+			want: []string{"run", "--out-format", "github-actions", "./a", "./b/c"},
+		},
+		{
+			name: "format and file",
+			cfg:  LintConfig{OutputFormat: "junit-xml", OutputFile: "lint.xml"},
+			want: []string{"run", "--out-format", "colored-line-number,junit-xml:lint.xml", "./a", "./b/c"},
+		},
+		{
+			name: "all options",
+			cfg: LintConfig{
+				ConfigPath:   "ci/lint.yml",
+				Timeout:      5 * time.Minute,
+				NewFromRev:   "HEAD~1",
+				OutputFormat: "checkstyle",
+				OutputFile:   "out/lint.xml",
+			},
+			want: []string{
+				"run",
+				"--config", "ci/lint.yml",
+				"--timeout", "5m0s",
+				"--new-from-rev", "HEAD~1",
+				"--out-format", "colored-line-number,checkstyle:out/lint.xml",
+				"./a", "./b/c",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := golangciLintArgs(tt.cfg, dirs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("golangciLintArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGolangciLintOutputFileRequiresFormat(t *testing.T) {
+	err := GolangciLint(LintConfig{OutputFile: "lint.xml"})
+	if err == nil || !strings.Contains(err.Error(), "requires an output format") {
+		t.Errorf("GolangciLint() error = %v, want the missing format reported", err)
+	}
+}
+
+// fakeGolangciLint records its arguments to $FAKE_LINT_ARGS and exits with $FAKE_LINT_EXIT
+const fakeGolangciLint = `package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	ioutil.WriteFile(os.Getenv("FAKE_LINT_ARGS"), []byte(strings.Join(os.Args[1:], "\n")), 0644)
+	code, _ := strconv.Atoi(os.Getenv("FAKE_LINT_EXIT"))
+	os.Exit(code)
+}
+`
+
+func TestGolangciLintInstallsMissingTool(t *testing.T) {
+	const modPath = "github.com/golangci/golangci-lint"
+	proxyDir, err := ioutil.TempDir("", "lint-proxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(proxyDir)
+	writeFixtureModuleProxy(t, proxyDir, modPath, GolangciLintVersion, map[string]string{
+		"go.mod":                    "module " + modPath + "\n\ngo 1.14\n",
+		"cmd/golangci-lint/main.go": fakeGolangciLint,
+	})
+	defer setEnv(t, "GOPROXY", "file://"+filepath.ToSlash(proxyDir))()
+	defer setEnv(t, "GONOSUMDB", modPath)()
+	defer setEnv(t, "GOFLAGS", "-mod=mod -modcacherw")()
+	modCache, err := ioutil.TempDir("", "lint-modcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(modCache)
+	defer setEnv(t, "GOMODCACHE", modCache)()
+
+	binDir, err := ioutil.TempDir("", "lint-bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(binDir)
+	defer setToolsBinDir(binDir)()
+	argsFile := filepath.Join(binDir, "args.txt")
+	defer setEnv(t, "FAKE_LINT_ARGS", argsFile)()
+
+	files := map[string]string{
+		"main.go": "package main\n",
+		"a/a.go":  "package a\n",
+	}
+	inFixtureModule(t, files, func(dir string) {
+		if err := GolangciLint(LintConfig{Timeout: time.Minute, OutputFormat: "github-actions"}); err != nil {
+			t.Fatalf("GolangciLint() error = %v", err)
+		}
+		if _, ok := findTool("golangci-lint"); !ok {
+			t.Errorf("golangci-lint was not installed into %s", binDir)
+		}
+		args, err := ioutil.ReadFile(argsFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"run", "--timeout", "1m0s", "--out-format", "github-actions", ".", "./a"}
+		if got := strings.Split(string(args), "\n"); !reflect.DeepEqual(got, want) {
+			t.Errorf("golangci-lint called with %q, want %q", got, want)
+		}
+
+		defer setEnv(t, "FAKE_LINT_EXIT", "1")()
+		if err := GolangciLint(LintConfig{}); err == nil || !strings.Contains(err.Error(), "linting failed") {
+			t.Errorf("GolangciLint() error = %v, want the lint failure reported", err)
+		}
+	})
+}
+
+func TestGoDirs(t *testing.T) {
+	files := map[string]string{
+		"main.go":         "package main\n",
+		"a/a.go":          "package a\n",
+		"a/testdata/t.go": "package t\n",
+		"docs/readme.md":  "# docs\n",
+		"b/c/c.go":        "package c\n",
+		"vendor/v/v.go":   "package v\n",
+	}
+	inFixtureModule(t, files, func(dir string) {
+		dirs, err := util.GetProjectDirectoriesWithRoot(util.GoLintExcludes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := goDirs(dirs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{".", "./a", "./b/c"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("goDirs() = %q, want %q", got, want)
+		}
+	})
+}
@@ -23,22 +23,17 @@ import (
 	"regexp"
 	"strings"
 
-	"github.com/magefile/mage/mg"
 	"github.com/magefile/mage/sh"
+
 	"github.com/zolia/go-ci/shell"
 	"github.com/zolia/go-ci/util"
 )
 
-// Checks if golint exists, if not installs it
+// GetLint installs the golint binary if it is missing
 func GetLint() error {
-	path, _ := util.GetGoBinaryPath("golint")
-	if path != "" {
-		fmt.Println("Tool 'golint' already installed")
-		return nil
-	}
-	err := sh.RunV("go", "get", "-u", "golang.org/x/lint/golint")
+	_, err := EnsureTool(goLintImportPath, GoLintVersion)
 	if err != nil {
-		fmt.Printf("Could not go get golint: %s\n", err)
+		fmt.Printf("Could not install golint: %s\n", err)
 		return err
 	}
 	return nil
@@ -81,8 +76,7 @@ func formatAndPrintGoLintOutput(rawGolint string) {
 
 // GoLint checks for linting errors in the solution
 func GoLint(pathToCheck string, excludes ...string) error {
-	mg.Deps(GetLint)
-	golintPath, err := util.GetGoBinaryPath("golint")
+	golintPath, err := EnsureTool(goLintImportPath, GoLintVersion)
 	if err != nil {
 		return err
 	}
@@ -122,8 +116,7 @@ func GoLint(pathToCheck string, excludes ...string) error {
 // Example:
 //     commands.GoLintD(".", "docs")
 func GoLintD(dir string, excludes ...string) error {
-	mg.Deps(GetLint)
-	golintBin, err := util.GetGoBinaryPath("golint")
+	golintBin, err := EnsureTool(goLintImportPath, GoLintVersion)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,271 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/magefile/mage/sh"
+	"github.com/pkg/errors"
+)
+
+// GoModTidyCheck fails when go.mod or go.sum are not tidy, printing the diff go mod tidy would apply.
+// The working tree is left untouched.
+func GoModTidyCheck() error {
+	diff, err := goModTidy(false)
+	if err != nil {
+		return err
+	}
+	if diff != "" {
+		fmt.Println("gomod: go.mod/go.sum are not tidy, go mod tidy would apply:")
+		fmt.Print(diff)
+		return errors.New("gomod: go.mod/go.sum are not tidy, run go mod tidy")
+	}
+	fmt.Println("gomod: go.mod/go.sum are tidy")
+	return nil
+}
+
+// GoModTidy runs go mod tidy and prints the diff it applied
+func GoModTidy() error {
+	diff, err := goModTidy(true)
+	if err != nil {
+		return err
+	}
+	if diff == "" {
+		fmt.Println("gomod: go.mod/go.sum are already tidy")
+		return nil
+	}
+	fmt.Println("gomod: go mod tidy applied:")
+	fmt.Print(diff)
+	return nil
+}
+
+// goModTidy runs go mod tidy and returns the diff of go.mod and go.sum.
+// Unless keep is set, or when tidy fails, the original files are restored.
+func goModTidy(keep bool) (diff string, err error) {
+	goMod, err := sh.Output("go", "env", "GOMOD")
+	if err != nil {
+		return "", err
+	}
+	if goMod == "" || goMod == os.DevNull {
+		return "", errors.New("gomod: not in a module")
+	}
+	files := []string{goMod, filepath.Join(filepath.Dir(goMod), "go.sum")}
+
+	originals := make(map[string][]byte)
+	for _, f := range files {
+		contents, err := ioutil.ReadFile(f)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		originals[f] = contents
+	}
+	defer func() {
+		if keep && err == nil {
+			return
+		}
+		if restoreErr := restoreFiles(files, originals); restoreErr != nil && err == nil {
+			err = restoreErr
+		}
+	}()
+
+	var stderr bytes.Buffer
+	_, err = sh.Exec(map[string]string{"GOFLAGS": goFlagsWithoutMod()}, os.Stdout, &stderr, "go", "mod", "tidy")
+	if err != nil {
+		fmt.Print(stderr.String())
+		if strings.Contains(stderr.String(), "requires go") {
+			return "", errors.Wrap(err, "gomod: the go version in go.mod is newer than the installed toolchain")
+		}
+		return "", errors.Wrap(err, "gomod: go mod tidy failed")
+	}
+
+	var b strings.Builder
+	for _, f := range files {
+		tidied, err := ioutil.ReadFile(f)
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		b.WriteString(unifiedDiff(filepath.Base(f), string(originals[f]), string(tidied)))
+	}
+	return b.String(), nil
+}
+
+func restoreFiles(files []string, originals map[string][]byte) error {
+	for _, f := range files {
+		contents, ok := originals[f]
+		if !ok {
+			if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		if err := ioutil.WriteFile(f, contents, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// goFlagsWithoutMod drops -mod from GOFLAGS, since go mod tidy refuses to run with it
+func goFlagsWithoutMod() string {
+	var flags []string
+	for _, f := range strings.Fields(os.Getenv("GOFLAGS")) {
+		if strings.HasPrefix(f, "-mod=") {
+			continue
+		}
+		flags = append(flags, f)
+	}
+	return strings.Join(flags, " ")
+}
+
+const diffContext = 3
+
+// unifiedDiff returns a unified diff of two texts, or an empty string when they are equal
+func unifiedDiff(name, a, b string) string {
+	if a == b {
+		return ""
+	}
+	aLines, bLines := splitLines(a), splitLines(b)
+
+	// only the lines between the common prefix and suffix go through the quadratic LCS,
+	// which keeps large files like go.sum with a few changed lines cheap
+	prefix := 0
+	for prefix < len(aLines) && prefix < len(bLines) && aLines[prefix] == bLines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(aLines)-prefix && suffix < len(bLines)-prefix &&
+		aLines[len(aLines)-1-suffix] == bLines[len(bLines)-1-suffix] {
+		suffix++
+	}
+	var ops []diffOp
+	for _, l := range aLines[:prefix] {
+		ops = append(ops, diffOp{' ', l})
+	}
+	ops = append(ops, diffLines(aLines[prefix:len(aLines)-suffix], bLines[prefix:len(bLines)-suffix])...)
+	for _, l := range aLines[len(aLines)-suffix:] {
+		ops = append(ops, diffOp{' ', l})
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- a/%s\n+++ b/%s\n", name, name)
+	aLine, bLine := 1, 1
+	for start := 0; start < len(ops); {
+		if ops[start].kind == ' ' {
+			start++
+			aLine++
+			bLine++
+			continue
+		}
+		// extend the hunk while changes are closer than twice the context
+		end := start
+		for k := start; k < len(ops); k++ {
+			if ops[k].kind != ' ' {
+				end = k + 1
+			} else if k-end >= 2*diffContext {
+				break
+			}
+		}
+		from := start - diffContext
+		if from < 0 {
+			from = 0
+		}
+		to := end + diffContext
+		if to > len(ops) {
+			to = len(ops)
+		}
+		hunkA, hunkB := aLine-(start-from), bLine-(start-from)
+		var aCount, bCount int
+		var body strings.Builder
+		for _, o := range ops[from:to] {
+			fmt.Fprintf(&body, "%c%s\n", o.kind, o.text)
+			if o.kind != '+' {
+				aCount++
+			}
+			if o.kind != '-' {
+				bCount++
+			}
+		}
+		// an empty range starts at the line before it, e.g. -0,0 for a file that did not exist
+		if aCount == 0 {
+			hunkA--
+		}
+		if bCount == 0 {
+			hunkB--
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n%s", hunkA, aCount, hunkB, bCount, body.String())
+		for _, o := range ops[start:to] {
+			if o.kind != '+' {
+				aLine++
+			}
+			if o.kind != '-' {
+				bLine++
+			}
+		}
+		start = to
+	}
+	return out.String()
+}
+
+type diffOp struct {
+	kind byte
+	text string
+}
+
+// diffLines returns the edit script turning a into b based on their longest common subsequence
+func diffLines(a, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	return ops
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,91 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numberLines returns the numbers from 1 to n one per line, replacing the given lines
+func numberLines(n int, replace map[int]string) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		if r, ok := replace[i]; ok {
+			b.WriteString(r)
+		} else {
+			fmt.Fprintf(&b, "%d\n", i)
+		}
+	}
+	return b.String()
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "equal",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: "",
+		},
+		{
+			name: "new file",
+			a:    "",
+			b:    "a\nb\n",
+			want: "@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "removed file",
+			a:    "a\nb\n",
+			b:    "",
+			want: "@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name: "separate hunks",
+			a:    numberLines(20, nil),
+			b:    numberLines(20, map[int]string{2: "two\n", 10: "ten\n"}),
+			want: "@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n" +
+				"@@ -7,7 +7,7 @@\n 7\n 8\n 9\n-10\n+ten\n 11\n 12\n 13\n",
+		},
+		{
+			name: "insert near the end",
+			a:    numberLines(20, nil),
+			b:    numberLines(20, map[int]string{19: "19\nx\n"}),
+			want: "@@ -17,4 +17,5 @@\n 17\n 18\n 19\n+x\n 20\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.want
+			if want != "" {
+				want = "--- a/go.sum\n+++ b/go.sum\n" + want
+			}
+			if got := unifiedDiff("go.sum", tt.a, tt.b); got != want {
+				t.Errorf("unifiedDiff() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiffLargeFile(t *testing.T) {
+	// without trimming the common prefix and suffix this needs a 10^10 cell LCS table
+	const lines = 100000
+	a := numberLines(lines, nil)
+	b := numberLines(lines, map[int]string{50000: "changed\n"})
+	want := "--- a/go.sum\n+++ b/go.sum\n" +
+		"@@ -49997,7 +49997,7 @@\n 49997\n 49998\n 49999\n-50000\n+changed\n 50001\n 50002\n 50003\n"
+	if got := unifiedDiff("go.sum", a, b); got != want {
+		t.Errorf("unifiedDiff() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -18,9 +18,18 @@
 package commands
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
 
 	"github.com/magefile/mage/sh"
+	"github.com/pkg/errors"
+
+	"github.com/zolia/go-ci/util"
 )
 
 // GoVet checks that the source is compliant with go vet
@@ -35,3 +44,150 @@ func GoVet(path string, additionalArgs ...string) error {
 	fmt.Println("govet: all files are compliant")
 	return nil
 }
+
+// GoVetConfig configures GoVetDWith
+type GoVetConfig struct {
+	// Tags are build tags to use
+	Tags []string
+}
+
+// GoVetD checks that the source is compliant with go vet, skipping excluded packages.
+// All findings are aggregated into a single error, one diagnostic per line.
+//
+// Excludes are matched against package directories with util.IsPathExcluded, like GoImportsD, GoLintD and CopyrightD do.
+//
+// Example:
+//     commands.GoVetD(".", "docs")
+func GoVetD(dir string, excludes ...string) error {
+	return GoVetDWith(GoVetConfig{}, dir, excludes...)
+}
+
+// GoVetDWith checks that the source is compliant with go vet using the given config, skipping excluded packages.
+func GoVetDWith(cfg GoVetConfig, dir string, excludes ...string) error {
+	pattern := path.Join(dir, "...")
+	if !path.IsAbs(pattern) {
+		pattern = "./" + pattern
+	}
+	packages, err := util.GetPackagePathsWithDirExcludes(pattern, excludes...)
+	if err != nil {
+		fmt.Printf("govet: go list crashed: %s\n", err)
+		return err
+	}
+	packages = nonEmpty(packages)
+	if len(packages) == 0 {
+		fmt.Println("govet: no packages to check")
+		return nil
+	}
+
+	args := []string{"vet", "-json"}
+	if len(cfg.Tags) != 0 {
+		args = append(args, "-tags", strings.Join(cfg.Tags, ","))
+	}
+	args = append(args, packages...)
+	var stdout, stderr bytes.Buffer
+	_, err = sh.Exec(nil, &stdout, &stderr, "go", args...)
+	if err != nil {
+		fmt.Print(stderr.String())
+		fmt.Printf("govet: error executing %s\n", err)
+		return err
+	}
+
+	// go vet -json writes to stdout since Go 1.25 and to stderr before, both exiting 0 on findings
+	out := stdout.String()
+	if !strings.Contains(out, "{") {
+		out = stderr.String()
+	}
+	findings, err := parseVetOutput(out)
+	if err != nil {
+		fmt.Print(out)
+		fmt.Printf("govet: could not parse output: %s\n", err)
+		return errors.Wrap(err, "govet: could not parse output")
+	}
+	sort.Strings(findings)
+	if len(findings) != 0 {
+		fmt.Println("govet: the following issues were found:")
+		for _, f := range findings {
+			fmt.Println(f)
+		}
+		return errors.Errorf("govet: %d issue(s) found:\n%s", len(findings), strings.Join(findings, "\n"))
+	}
+	fmt.Println("govet: all files are compliant")
+	return nil
+}
+
+// parseVetOutput parses `go vet -json` output, skipping the lines outside of the JSON objects,
+// like the "# package" headers of older toolchains or "go: downloading ..." messages
+func parseVetOutput(out string) ([]string, error) {
+	var objects []string
+	for out != "" {
+		if !strings.HasPrefix(out, "{") {
+			next := strings.Index(out, "\n{")
+			if next == -1 {
+				break
+			}
+			out = out[next+1:]
+			continue
+		}
+		dec := json.NewDecoder(strings.NewReader(out))
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		objects = append(objects, string(raw))
+		out = out[dec.InputOffset():]
+		out = strings.TrimLeft(out, " \t\r\n")
+	}
+	return parseVetJSON(strings.NewReader(strings.Join(objects, "\n")))
+}
+
+type vetDiagnostic struct {
+	Posn    string `json:"posn"`
+	Message string `json:"message"`
+}
+
+// parseVetJSON reads the stream of objects printed by `go vet -json`
+// and returns findings formatted as "file:line:col: message (analyzer)".
+func parseVetJSON(r io.Reader) ([]string, error) {
+	var findings []string
+	dec := json.NewDecoder(r)
+	for {
+		var report map[string]map[string]json.RawMessage
+		err := dec.Decode(&report)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, analyzers := range report {
+			for analyzer, raw := range analyzers {
+				var diagnostics []vetDiagnostic
+				if err := json.Unmarshal(raw, &diagnostics); err != nil {
+					// analyzer failures are reported as {"error": "..."} instead of a list
+					var failure struct {
+						Error string `json:"error"`
+					}
+					if err := json.Unmarshal(raw, &failure); err != nil {
+						return nil, err
+					}
+					findings = append(findings, fmt.Sprintf("%s: %s", analyzer, failure.Error))
+					continue
+				}
+				for _, d := range diagnostics {
+					findings = append(findings, fmt.Sprintf("%s: %s (%s)", d.Posn, d.Message, analyzer))
+				}
+			}
+		}
+	}
+	return findings, nil
+}
+
+func nonEmpty(lines []string) []string {
+	result := make([]string, 0, len(lines))
+	for _, l := range lines {
+		if strings.TrimSpace(l) != "" {
+			result = append(result, l)
+		}
+	}
+	return result
+}
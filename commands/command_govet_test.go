@@ -0,0 +1,94 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseVetOutput(t *testing.T) {
+	const report = `{
+	"example.com/vt/a": {
+		"printf": [
+			{
+				"posn": "/tmp/vt/a/a.go:3:23",
+				"message": "bad format"
+			}
+		]
+	}
+}
+`
+	tests := []struct {
+		name    string
+		out     string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", out: ""},
+		{name: "no findings", out: "{}\n"},
+		{
+			name: "stdout format",
+			out:  report + "{}\n",
+			want: []string{"/tmp/vt/a/a.go:3:23: bad format (printf)"},
+		},
+		{
+			name: "stderr format with package headers",
+			out:  "# example.com/vt/a\n" + report + "# example.com/vt/b\n{}\n",
+			want: []string{"/tmp/vt/a/a.go:3:23: bad format (printf)"},
+		},
+		{
+			name: "analyzer error",
+			out:  `{"example.com/vt/a": {"printf": {"error": "boom"}}}`,
+			want: []string{"printf: boom"},
+		},
+		{
+			name: "toolchain messages",
+			out: "go: downloading golang.org/x/text v0.3.7\n" + report +
+				"go: warning: \"./...\" matched only vendored packages\n{}\n",
+			want: []string{"/tmp/vt/a/a.go:3:23: bad format (printf)"},
+		},
+		{name: "truncated output", out: "go: downloading golang.org/x/text v0.3.7\n{\"example.com/vt/a\": ", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseVetOutput(tt.out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseVetOutput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseVetOutput() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGoVetDExcludes(t *testing.T) {
+	const badPrintf = "package %s\n\nimport \"fmt\"\n\nfunc F() { fmt.Printf(\"%%d\", \"x\") }\n"
+	files := map[string]string{
+		"main.go":                     "package main\n\nfunc main() {}\n",
+		"docs/docs.go":                fmt.Sprintf(badPrintf, "docs"),
+		"internal/docsgen/docsgen.go": fmt.Sprintf(badPrintf, "docsgen"),
+	}
+	inFixtureModule(t, files, func(dir string) {
+		err := GoVetD(".", "docs")
+		if err == nil {
+			t.Fatal("GoVetD() expected the docsgen finding")
+		}
+		if !strings.Contains(err.Error(), filepath.Join("internal", "docsgen", "docsgen.go")) {
+			t.Errorf("GoVetD() error = %v, want internal/docsgen checked", err)
+		}
+		if strings.Contains(err.Error(), filepath.Join("docs", "docs.go")) {
+			t.Errorf("GoVetD() error = %v, want docs excluded", err)
+		}
+	})
+}
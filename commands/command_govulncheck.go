@@ -0,0 +1,301 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/magefile/mage/sh"
+	"github.com/pkg/errors"
+
+	"github.com/zolia/go-ci/util"
+)
+
+const govulncheckImportPath = "golang.org/x/vuln/cmd/govulncheck"
+
+// GovulncheckVersion is the default pinned govulncheck version
+var GovulncheckVersion = "v1.1.3"
+
+// Govulncheck strictness levels
+const (
+	// VulnFailOnAny fails on any vulnerable module in the dependency graph
+	VulnFailOnAny = "any"
+	// VulnFailOnReachable fails only on vulnerable functions called from our code
+	VulnFailOnReachable = "reachable-only"
+)
+
+// VulnConfig configures Govulncheck
+type VulnConfig struct {
+	// Version of govulncheck to install when missing, defaults to GovulncheckVersion
+	Version string
+	// Excludes are packages to skip, matched the same way as in the other checks
+	Excludes []string
+	// IgnoreIDs acknowledges vulnerabilities by GO- or CVE- identifier, optionally until a date: "GO-2023-1234:2024-12-31".
+	// Expired entries are no longer ignored.
+	IgnoreIDs []string
+	// FailOn is VulnFailOnAny or VulnFailOnReachable (default)
+	FailOn string
+	// ReportFile, when set, receives a JSON report of all findings for audit
+	ReportFile string
+}
+
+// VulnFinding is a vulnerability found by govulncheck
+type VulnFinding struct {
+	ID           string   `json:"id"`
+	Aliases      []string `json:"aliases,omitempty"`
+	Summary      string   `json:"summary"`
+	Module       string   `json:"module"`
+	Version      string   `json:"version"`
+	FixedVersion string   `json:"fixed_version,omitempty"`
+	// Reachable is set when a vulnerable function is called from our code
+	Reachable bool `json:"reachable"`
+	// Symbols are the vulnerable functions called, as "package.function"
+	Symbols []string `json:"symbols,omitempty"`
+	Ignored bool     `json:"ignored"`
+}
+
+func (f VulnFinding) String() string {
+	s := fmt.Sprintf("%s %s@%s", f.ID, f.Module, f.Version)
+	if f.FixedVersion != "" {
+		s += " (fixed in " + f.FixedVersion + ")"
+	}
+	if len(f.Symbols) != 0 {
+		s += " calls " + strings.Join(f.Symbols, ", ")
+	}
+	return s + ": " + f.Summary
+}
+
+// Govulncheck checks the dependencies of the packages in the current module for known vulnerabilities
+//
+// Example:
+//     commands.Govulncheck(commands.VulnConfig{IgnoreIDs: []string{"GO-2023-1234:2024-12-31"}, ReportFile: "build/vuln.json"})
+func Govulncheck(cfg VulnConfig) error {
+	failOn := cfg.FailOn
+	if failOn == "" {
+		failOn = VulnFailOnReachable
+	}
+	if failOn != VulnFailOnAny && failOn != VulnFailOnReachable {
+		return errors.Errorf("govulncheck: unsupported FailOn %q", cfg.FailOn)
+	}
+	ignored, err := parseVulnIgnores(cfg.IgnoreIDs, time.Now())
+	if err != nil {
+		return err
+	}
+	version := cfg.Version
+	if version == "" {
+		version = GovulncheckVersion
+	}
+	vulncheckBin, err := EnsureTool(govulncheckImportPath, version)
+	if err != nil {
+		fmt.Println("govulncheck: tool not found")
+		return err
+	}
+
+	packages, err := util.GetPackagePathsWithExcludes("./...", cfg.Excludes...)
+	if err != nil {
+		fmt.Printf("govulncheck: go list crashed: %s\n", err)
+		return err
+	}
+	packages = nonEmpty(packages)
+	if len(packages) == 0 {
+		fmt.Println("govulncheck: no packages to check")
+		return nil
+	}
+
+	var stdout, stderr bytes.Buffer
+	args := append([]string{"-json"}, packages...)
+	if _, err := sh.Exec(nil, &stdout, &stderr, vulncheckBin, args...); err != nil {
+		fmt.Print(stderr.String())
+		fmt.Printf("govulncheck: error executing %s\n", err)
+		return err
+	}
+	findings, err := parseVulncheckJSON(&stdout)
+	if err != nil {
+		fmt.Printf("govulncheck: could not parse output: %s\n", err)
+		return err
+	}
+
+	var failing []VulnFinding
+	for i := range findings {
+		findings[i].Ignored = ignored[findings[i].ID] || anyIgnored(ignored, findings[i].Aliases)
+		if findings[i].Ignored {
+			continue
+		}
+		if failOn == VulnFailOnAny || findings[i].Reachable {
+			failing = append(failing, findings[i])
+		}
+	}
+
+	if cfg.ReportFile != "" {
+		report, err := json.MarshalIndent(findings, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(cfg.ReportFile, report, 0644); err != nil {
+			return err
+		}
+		fmt.Printf("govulncheck: report written to %s\n", cfg.ReportFile)
+	}
+
+	printVulnFindings(findings)
+	if len(failing) != 0 {
+		lines := make([]string, len(failing))
+		for i := range failing {
+			lines[i] = failing[i].String()
+		}
+		noun := "vulnerabilities"
+		if len(failing) == 1 {
+			noun = "vulnerability"
+		}
+		return errors.Errorf("govulncheck: %d %s found:\n%s", len(failing), noun, strings.Join(lines, "\n"))
+	}
+	fmt.Println("govulncheck: no vulnerabilities affecting the build")
+	return nil
+}
+
+func printVulnFindings(findings []VulnFinding) {
+	sections := []struct {
+		title string
+		match func(VulnFinding) bool
+	}{
+		{"reachable vulnerabilities", func(f VulnFinding) bool { return !f.Ignored && f.Reachable }},
+		{"vulnerabilities in dependencies, not called by our code", func(f VulnFinding) bool { return !f.Ignored && !f.Reachable }},
+		{"ignored vulnerabilities", func(f VulnFinding) bool { return f.Ignored }},
+	}
+	for _, s := range sections {
+		var lines []string
+		for _, f := range findings {
+			if s.match(f) {
+				lines = append(lines, f.String())
+			}
+		}
+		if len(lines) != 0 {
+			fmt.Printf("govulncheck: %s:\n%s\n", s.title, strings.Join(lines, "\n"))
+		}
+	}
+}
+
+// parseVulnIgnores returns IDs that are still ignored at the given time.
+// An entry stays ignored until the end of its expiry day, UTC.
+func parseVulnIgnores(entries []string, now time.Time) (map[string]bool, error) {
+	ignored := make(map[string]bool)
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) == 2 {
+			until, err := time.Parse("2006-01-02", parts[1])
+			if err != nil {
+				return nil, errors.Wrapf(err, "govulncheck: invalid expiry date of ignored %s", parts[0])
+			}
+			if !now.Before(until.AddDate(0, 0, 1)) {
+				fmt.Printf("govulncheck: ignore of %s expired on %s\n", parts[0], parts[1])
+				continue
+			}
+		}
+		ignored[parts[0]] = true
+	}
+	return ignored, nil
+}
+
+func anyIgnored(ignored map[string]bool, ids []string) bool {
+	for _, id := range ids {
+		if ignored[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// vulncheckMessage is a single message of the `govulncheck -json` stream
+type vulncheckMessage struct {
+	OSV *struct {
+		ID      string   `json:"id"`
+		Aliases []string `json:"aliases"`
+		Summary string   `json:"summary"`
+	} `json:"osv"`
+	Finding *struct {
+		OSV          string `json:"osv"`
+		FixedVersion string `json:"fixed_version"`
+		Trace        []struct {
+			Module   string `json:"module"`
+			Version  string `json:"version"`
+			Package  string `json:"package"`
+			Function string `json:"function"`
+			Receiver string `json:"receiver"`
+		} `json:"trace"`
+	} `json:"finding"`
+}
+
+// parseVulncheckJSON merges the findings of the stream by vulnerability ID, sorted by ID
+func parseVulncheckJSON(r io.Reader) ([]VulnFinding, error) {
+	findings := make(map[string]*VulnFinding)
+	osvs := make(map[string]VulnFinding)
+	dec := json.NewDecoder(r)
+	for {
+		var msg vulncheckMessage
+		err := dec.Decode(&msg)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if msg.OSV != nil {
+			osvs[msg.OSV.ID] = VulnFinding{ID: msg.OSV.ID, Aliases: msg.OSV.Aliases, Summary: msg.OSV.Summary}
+		}
+		if msg.Finding == nil || len(msg.Finding.Trace) == 0 {
+			continue
+		}
+		f, ok := findings[msg.Finding.OSV]
+		if !ok {
+			f = &VulnFinding{ID: msg.Finding.OSV}
+			findings[msg.Finding.OSV] = f
+		}
+		// the first frame is the vulnerable symbol, package or module
+		frame := msg.Finding.Trace[0]
+		f.Module, f.Version, f.FixedVersion = frame.Module, frame.Version, msg.Finding.FixedVersion
+		if frame.Function != "" {
+			f.Reachable = true
+			symbol := frame.Package + "." + frame.Function
+			if frame.Receiver != "" {
+				symbol = frame.Package + "." + frame.Receiver + "." + frame.Function
+			}
+			if !containsString(f.Symbols, symbol) {
+				f.Symbols = append(f.Symbols, symbol)
+			}
+		}
+	}
+
+	result := make([]VulnFinding, 0, len(findings))
+	for id, f := range findings {
+		osv := osvs[id]
+		f.Aliases, f.Summary = osv.Aliases, osv.Summary
+		sort.Strings(f.Symbols)
+		result = append(result, *f)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
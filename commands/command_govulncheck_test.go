@@ -0,0 +1,115 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// vulncheckStream is `govulncheck -json` output with module, package and symbol level findings
+const vulncheckStream = `{"config":{"protocol_version":"v1.0.0","scanner_name":"govulncheck","scan_level":"symbol"}}
+{"progress":{"message":"Scanning your code and 12 packages across 3 dependent modules for known vulnerabilities..."}}
+{"osv":{"id":"GO-2021-0113","aliases":["CVE-2021-38561"],"summary":"Out-of-bounds read in golang.org/x/text/language"}}
+{"osv":{"id":"GO-2022-0493","aliases":["CVE-2022-29526"],"summary":"Incorrect privilege reporting in syscall"}}
+{"osv":{"id":"GO-2023-1840","summary":"Unsafe behavior in setuid/setgid binaries in runtime"}}
+{"finding":{"osv":"GO-2021-0113","fixed_version":"v0.3.7","trace":[{"module":"golang.org/x/text","version":"v0.3.5"}]}}
+{"finding":{"osv":"GO-2021-0113","fixed_version":"v0.3.7","trace":[{"module":"golang.org/x/text","version":"v0.3.5","package":"golang.org/x/text/language"}]}}
+{"finding":{"osv":"GO-2021-0113","fixed_version":"v0.3.7","trace":[{"module":"golang.org/x/text","version":"v0.3.5","package":"golang.org/x/text/language","function":"Parse"},{"module":"example.com/app","package":"example.com/app","function":"main"}]}}
+{"finding":{"osv":"GO-2021-0113","fixed_version":"v0.3.7","trace":[{"module":"golang.org/x/text","version":"v0.3.5","package":"golang.org/x/text/language","function":"String","receiver":"Tag"},{"module":"example.com/app","package":"example.com/app","function":"main"}]}}
+{"finding":{"osv":"GO-2022-0493","fixed_version":"v0.0.0-20220412211240-33da011f77ad","trace":[{"module":"golang.org/x/sys","version":"v0.0.0-20210330210617-4fbd30eecc44","package":"golang.org/x/sys/unix"}]}}
+{"finding":{"osv":"GO-2023-1840","fixed_version":"v1.20.5","trace":[{"module":"stdlib","version":"v1.20.0"}]}}
+`
+
+func TestParseVulncheckJSON(t *testing.T) {
+	findings, err := parseVulncheckJSON(strings.NewReader(vulncheckStream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []VulnFinding{
+		{
+			ID:           "GO-2021-0113",
+			Aliases:      []string{"CVE-2021-38561"},
+			Summary:      "Out-of-bounds read in golang.org/x/text/language",
+			Module:       "golang.org/x/text",
+			Version:      "v0.3.5",
+			FixedVersion: "v0.3.7",
+			Reachable:    true,
+			Symbols:      []string{"golang.org/x/text/language.Parse", "golang.org/x/text/language.Tag.String"},
+		},
+		{
+			ID:           "GO-2022-0493",
+			Aliases:      []string{"CVE-2022-29526"},
+			Summary:      "Incorrect privilege reporting in syscall",
+			Module:       "golang.org/x/sys",
+			Version:      "v0.0.0-20210330210617-4fbd30eecc44",
+			FixedVersion: "v0.0.0-20220412211240-33da011f77ad",
+		},
+		{
+			ID:           "GO-2023-1840",
+			Summary:      "Unsafe behavior in setuid/setgid binaries in runtime",
+			Module:       "stdlib",
+			Version:      "v1.20.0",
+			FixedVersion: "v1.20.5",
+		},
+	}
+	if !reflect.DeepEqual(findings, want) {
+		t.Errorf("parseVulncheckJSON() =\n%+v\nwant\n%+v", findings, want)
+	}
+}
+
+func TestParseVulncheckJSONMalformed(t *testing.T) {
+	if _, err := parseVulncheckJSON(strings.NewReader(`{"osv":`)); err == nil {
+		t.Error("parseVulncheckJSON() expected an error for truncated output")
+	}
+}
+
+func TestParseVulnIgnores(t *testing.T) {
+	entries := []string{"GO-2021-0113", "CVE-2022-29526:2024-12-31"}
+	tests := []struct {
+		name string
+		now  time.Time
+		want map[string]bool
+	}{
+		{
+			name: "before expiry day",
+			now:  time.Date(2024, 12, 30, 12, 0, 0, 0, time.UTC),
+			want: map[string]bool{"GO-2021-0113": true, "CVE-2022-29526": true},
+		},
+		{
+			name: "last second of expiry day",
+			now:  time.Date(2024, 12, 31, 23, 59, 59, 0, time.UTC),
+			want: map[string]bool{"GO-2021-0113": true, "CVE-2022-29526": true},
+		},
+		{
+			name: "day after expiry",
+			now:  time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			want: map[string]bool{"GO-2021-0113": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseVulnIgnores(entries, tt.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseVulnIgnores() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseVulnIgnoresInvalidDate(t *testing.T) {
+	if _, err := parseVulnIgnores([]string{"GO-2021-0113:31-12-2024"}, time.Now()); err == nil {
+		t.Error("parseVulnIgnores() expected an error for an invalid date")
+	}
+}
@@ -0,0 +1,247 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zolia/go-ci/util"
+)
+
+// License header template placeholders
+const (
+	LicenseYearPlaceholder   = "{{YEAR}}"
+	LicenseAuthorPlaceholder = "{{AUTHOR}}"
+)
+
+// LicenseHeaderAuthor is rendered in place of the author placeholder by FixLicenseHeaders
+var LicenseHeaderAuthor = ""
+
+var (
+	generatedRegex       = regexp.MustCompile(`(?m)^// Code generated .* DO NOT EDIT\.$`)
+	licenseMentionRegex  = regexp.MustCompile(`(?i)copyright|license`)
+	buildConstraintRegex = regexp.MustCompile(`^(//go:build |// \+build )`)
+)
+
+// CheckLicenseHeaders checks that every .go file of the project starts with the header from the template file.
+// The template is the header exactly as it appears in files, where {{YEAR}} matches any year (or year range)
+// and {{AUTHOR}} matches any author. Build constraints may precede the header, generated files are skipped.
+//
+// Example:
+//     commands.CheckLicenseHeaders("ci/license.tmpl", "docs")
+func CheckLicenseHeaders(templatePath string, excludes ...string) error {
+	tmpl, err := loadLicenseTemplate(templatePath)
+	if err != nil {
+		fmt.Printf("license: could not load template: %s\n", err)
+		return err
+	}
+	files, err := licenseFiles(excludes)
+	if err != nil {
+		fmt.Printf("license: error listing files: %s\n", err)
+		return err
+	}
+	var badFiles []string
+	for _, file := range files {
+		src, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if skipLicenseCheck(string(src)) {
+			continue
+		}
+		_, rest := splitBuildConstraints(string(src))
+		if !tmpl.matches(rest) {
+			badFiles = append(badFiles, file)
+		}
+	}
+	if len(badFiles) != 0 {
+		fmt.Println("license: the following files are missing the license header or it does not match the template:")
+		for _, f := range badFiles {
+			fmt.Println(f)
+		}
+		return errors.New("license: missing or mismatching license headers")
+	}
+	fmt.Println("license: all files have the required license header!")
+	return nil
+}
+
+// FixLicenseHeaders inserts the header rendered from the template (with the current year and LicenseHeaderAuthor)
+// into every .go file that does not have it, replacing an existing copyright/license comment at the top.
+// Build constraints are kept at the very top. Returns the list of modified files.
+//
+// Example:
+//     commands.FixLicenseHeaders("ci/license.tmpl", "docs")
+func FixLicenseHeaders(templatePath string, excludes ...string) ([]string, error) {
+	tmpl, err := loadLicenseTemplate(templatePath)
+	if err != nil {
+		fmt.Printf("license: could not load template: %s\n", err)
+		return nil, err
+	}
+	header, err := tmpl.render(time.Now().Year(), LicenseHeaderAuthor)
+	if err != nil {
+		return nil, err
+	}
+	files, err := licenseFiles(excludes)
+	if err != nil {
+		fmt.Printf("license: error listing files: %s\n", err)
+		return nil, err
+	}
+	var fixed []string
+	for _, file := range files {
+		src, err := ioutil.ReadFile(file)
+		if err != nil {
+			return fixed, err
+		}
+		if skipLicenseCheck(string(src)) {
+			continue
+		}
+		constraints, rest := splitBuildConstraints(string(src))
+		if tmpl.matches(rest) {
+			continue
+		}
+		content := constraints + header + "\n" + stripLicenseComment(rest)
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			return fixed, err
+		}
+		fixed = append(fixed, file)
+	}
+	if len(fixed) != 0 {
+		fmt.Println("license: added license header to the following files:")
+		for _, f := range fixed {
+			fmt.Println(f)
+		}
+	} else {
+		fmt.Println("license: all files have the required license header!")
+	}
+	return fixed, nil
+}
+
+type licenseTemplate struct {
+	text  string
+	regex *regexp.Regexp
+}
+
+func loadLicenseTemplate(templatePath string) (*licenseTemplate, error) {
+	contents, err := ioutil.ReadFile(templatePath)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimSpace(strings.Replace(string(contents), "\r\n", "\n", -1))
+	if text == "" {
+		return nil, errors.Errorf("license template %s is empty", templatePath)
+	}
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = regexp.QuoteMeta(strings.TrimRight(line, " \t"))
+		line = strings.Replace(line, regexp.QuoteMeta(LicenseYearPlaceholder), `\d{4}(\s*-\s*\d{4})?`, -1)
+		line = strings.Replace(line, regexp.QuoteMeta(LicenseAuthorPlaceholder), `.+`, -1)
+		lines = append(lines, line+`[ \t]*`)
+	}
+	regex, err := regexp.Compile(`^` + strings.Join(lines, `\r?\n`) + `(\r?\n|$)`)
+	if err != nil {
+		return nil, err
+	}
+	return &licenseTemplate{text: text, regex: regex}, nil
+}
+
+func (t *licenseTemplate) matches(src string) bool {
+	return t.regex.MatchString(strings.TrimLeft(src, "\r\n"))
+}
+
+func (t *licenseTemplate) render(year int, author string) (string, error) {
+	if strings.Contains(t.text, LicenseAuthorPlaceholder) && author == "" {
+		return "", errors.New("license: template has an author placeholder but LicenseHeaderAuthor is not set")
+	}
+	header := strings.Replace(t.text, LicenseYearPlaceholder, strconv.Itoa(year), -1)
+	header = strings.Replace(header, LicenseAuthorPlaceholder, author, -1)
+	return header + "\n", nil
+}
+
+func licenseFiles(excludes []string) ([]string, error) {
+	var allExcludes []string
+	allExcludes = append(allExcludes, excludes...)
+	allExcludes = append(allExcludes, util.GoLintExcludes()...)
+	return util.GetProjectGoFiles(allExcludes)
+}
+
+// skipLicenseCheck skips empty and generated files
+func skipLicenseCheck(src string) bool {
+	return strings.TrimSpace(src) == "" || generatedRegex.MatchString(src)
+}
+
+// splitBuildConstraints splits the leading build constraint lines, together with the blank lines following them, from the rest of the file
+func splitBuildConstraints(src string) (string, string) {
+	lines := strings.SplitAfter(src, "\n")
+	i := 0
+	for i < len(lines) && buildConstraintRegex.MatchString(lines[i]) {
+		i++
+	}
+	if i == 0 {
+		return "", src
+	}
+	for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+	constraints := strings.TrimRight(strings.Join(lines[:i], ""), "\r\n") + "\n\n"
+	return constraints, strings.Join(lines[i:], "")
+}
+
+// stripLicenseComment removes the leading comment when it mentions copyright or license
+// and is separated from the code by a blank line, so it is not a package doc comment.
+func stripLicenseComment(src string) string {
+	src = strings.TrimLeft(src, "\r\n")
+	comment, rest, ok := leadingComment(src)
+	if !ok || !licenseMentionRegex.MatchString(comment) {
+		return src
+	}
+	firstLine := rest
+	if i := strings.Index(rest, "\n"); i >= 0 {
+		firstLine = rest[:i]
+	}
+	if strings.TrimSpace(firstLine) != "" {
+		return src
+	}
+	return strings.TrimLeft(rest, " \t\r\n")
+}
+
+// leadingComment splits the comment at the start of src from the lines following it
+func leadingComment(src string) (string, string, bool) {
+	lines := strings.SplitAfter(src, "\n")
+	if strings.HasPrefix(src, "/*") {
+		idx := strings.Index(src, "*/")
+		if idx < 0 {
+			return "", "", false
+		}
+		end := idx + len("*/")
+		lineEnd := strings.Index(src[end:], "\n")
+		if lineEnd < 0 {
+			return src, "", true
+		}
+		if strings.TrimSpace(src[end:end+lineEnd]) != "" {
+			// code follows the comment on the same line
+			return "", "", false
+		}
+		return src[:end], src[end+lineEnd+1:], true
+	}
+	i := 0
+	for i < len(lines) && strings.HasPrefix(lines[i], "//") {
+		i++
+	}
+	if i == 0 {
+		return "", "", false
+	}
+	return strings.Join(lines[:i], ""), strings.Join(lines[i:], ""), true
+}
@@ -0,0 +1,211 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLicenseFilesIncludeRoot(t *testing.T) {
+	files := map[string]string{
+		"main.go":       "package main\n",
+		"a/a.go":        "package a\n",
+		"docs/x.go":     "package docs\n",
+		"vendor/v/v.go": "package v\n",
+	}
+	inFixtureModule(t, files, func(dir string) {
+		got, err := licenseFiles([]string{"docs"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"main.go", filepath.Join("a", "a.go")}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("licenseFiles() = %q, want %q", got, want)
+		}
+	})
+}
+
+const licenseFixtureTemplate = "// Copyright (c) {{YEAR}} {{AUTHOR}}\n// SPDX-License-Identifier: MIT\n"
+
+func TestLicenseTemplateMatches(t *testing.T) {
+	inFixtureModule(t, map[string]string{"license.tmpl": licenseFixtureTemplate}, func(dir string) {
+		tmpl, err := loadLicenseTemplate("license.tmpl")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tests := []struct {
+			src  string
+			want bool
+		}{
+			{"// Copyright (c) 2020 Acme\n// SPDX-License-Identifier: MIT\n\npackage a\n", true},
+			{"// Copyright (c) 2018-2020 Acme\n// SPDX-License-Identifier: MIT\n\npackage a\n", true},
+			{"// Copyright (c) 2018 - 2020 Acme Inc.\n// SPDX-License-Identifier: MIT\n", true},
+			{"\n// Copyright (c) 2020 Acme  \n// SPDX-License-Identifier: MIT\r\n\npackage a\n", true},
+			{"// Copyright (c) 20 Acme\n// SPDX-License-Identifier: MIT\n", false},
+			{"// Copyright (c) 2018- Acme\n// SPDX-License-Identifier: MIT\n", false},
+			{"// Copyright (c) 2020\n// SPDX-License-Identifier: MIT\n", false},
+			{"// Copyright (c) 2020 Acme\n// SPDX-License-Identifier: Apache-2.0\n", false},
+			{"package a\n\n// Copyright (c) 2020 Acme\n// SPDX-License-Identifier: MIT\n", false},
+		}
+		for _, tt := range tests {
+			if got := tmpl.matches(tt.src); got != tt.want {
+				t.Errorf("matches(%q) = %v, want %v", tt.src, got, tt.want)
+			}
+		}
+	})
+}
+
+func TestSplitBuildConstraints(t *testing.T) {
+	tests := []struct {
+		name                  string
+		src                   string
+		constraints, wantRest string
+	}{
+		{
+			name:     "none",
+			src:      "// Copyright\n\npackage a\n",
+			wantRest: "// Copyright\n\npackage a\n",
+		},
+		{
+			name:        "go:build and +build",
+			src:         "//go:build linux\n// +build linux\n\n\n// Copyright\n\npackage a\n",
+			constraints: "//go:build linux\n// +build linux\n\n",
+			wantRest:    "// Copyright\n\npackage a\n",
+		},
+		{
+			name:        "no blank line",
+			src:         "// +build linux\npackage a\n",
+			constraints: "// +build linux\n\n",
+			wantRest:    "package a\n",
+		},
+		{
+			name:     "constraint after a comment",
+			src:      "// Copyright\n// +build linux\n\npackage a\n",
+			wantRest: "// Copyright\n// +build linux\n\npackage a\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			constraints, rest := splitBuildConstraints(tt.src)
+			if constraints != tt.constraints || rest != tt.wantRest {
+				t.Errorf("splitBuildConstraints() = %q, %q, want %q, %q", constraints, rest, tt.constraints, tt.wantRest)
+			}
+		})
+	}
+}
+
+func TestStripLicenseComment(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "block license comment",
+			src:  "/*\n * Copyright (C) 2018 Someone\n */\n\npackage a\n",
+			want: "package a\n",
+		},
+		{
+			name: "line license comment",
+			src:  "\n// Licensed under the GPL\n// see LICENSE\n\n\npackage a\n",
+			want: "package a\n",
+		},
+		{
+			name: "package doc comment",
+			src:  "// Package a is licensed under MIT.\npackage a\n",
+			want: "// Package a is licensed under MIT.\npackage a\n",
+		},
+		{
+			name: "unrelated comment",
+			src:  "// Helpers for a.\n\npackage a\n",
+			want: "// Helpers for a.\n\npackage a\n",
+		},
+		{
+			name: "code after the comment on the same line",
+			src:  "/* Copyright */ package a\n",
+			want: "/* Copyright */ package a\n",
+		},
+		{
+			name: "unterminated comment",
+			src:  "/* Copyright\npackage a\n",
+			want: "/* Copyright\npackage a\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripLicenseComment(tt.src); got != tt.want {
+				t.Errorf("stripLicenseComment() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFixLicenseHeaders(t *testing.T) {
+	defer func(author string) { LicenseHeaderAuthor = author }(LicenseHeaderAuthor)
+	LicenseHeaderAuthor = "Acme"
+	header := fmt.Sprintf("// Copyright (c) %d Acme\n// SPDX-License-Identifier: MIT\n", time.Now().Year())
+
+	files := map[string]string{
+		"license.tmpl":      licenseFixtureTemplate,
+		"good.go":           "// Copyright (c) 2018-2020 Someone Else\n// SPDX-License-Identifier: MIT\n\npackage fixture\n",
+		"tagged.go":         "//go:build linux\n// +build linux\n\n// Copyright (c) 2020 Acme\n// SPDX-License-Identifier: MIT\n\npackage fixture\n",
+		"tagged_missing.go": "//go:build linux\n// +build linux\n\npackage fixture\n",
+		"wrong.go":          "/*\n * Copyright (C) 2018 Someone\n * Licensed under the GPL\n */\n\npackage fixture\n",
+		"doc.go":            "// Package fixture is a fixture.\npackage fixture\n",
+		"empty.go":          "",
+		"gen.go":            "// Code generated by stringer. DO NOT EDIT.\n\npackage fixture\n",
+	}
+	want := map[string]string{
+		"good.go":           files["good.go"],
+		"tagged.go":         files["tagged.go"],
+		"tagged_missing.go": "//go:build linux\n// +build linux\n\n" + header + "\npackage fixture\n",
+		"wrong.go":          header + "\npackage fixture\n",
+		"doc.go":            header + "\n// Package fixture is a fixture.\npackage fixture\n",
+		"empty.go":          "",
+		"gen.go":            files["gen.go"],
+	}
+	inFixtureModule(t, files, func(dir string) {
+		if err := CheckLicenseHeaders("license.tmpl"); err == nil {
+			t.Error("CheckLicenseHeaders() expected an error before fixing")
+		}
+
+		fixed, err := FixLicenseHeaders("license.tmpl")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wantFixed := []string{"doc.go", "tagged_missing.go", "wrong.go"}; !reflect.DeepEqual(fixed, wantFixed) {
+			t.Errorf("FixLicenseHeaders() = %q, want %q", fixed, wantFixed)
+		}
+		for name, content := range want {
+			got, err := ioutil.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != content {
+				t.Errorf("%s =\n%s\nwant\n%s", name, got, content)
+			}
+		}
+
+		if err := CheckLicenseHeaders("license.tmpl"); err != nil {
+			t.Errorf("CheckLicenseHeaders() after fixing error = %v", err)
+		}
+		fixed, err = FixLicenseHeaders("license.tmpl")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(fixed) != 0 {
+			t.Errorf("second FixLicenseHeaders() = %q, want no changes", fixed)
+		}
+	})
+}
@@ -0,0 +1,140 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/magefile/mage/sh"
+	"github.com/pkg/errors"
+
+	"github.com/zolia/go-ci/util"
+)
+
+const misspellImportPath = "github.com/client9/misspell/cmd/misspell"
+
+// MisspellVersion is the default pinned misspell version
+var MisspellVersion = "v0.3.4"
+
+// MisspellConfig configures Misspell
+type MisspellConfig struct {
+	// Version of misspell to install when missing, defaults to MisspellVersion
+	Version string
+	// Excludes are directories to skip, in addition to util.GoLintExcludes()
+	Excludes []string
+	// IgnoreWords are corrections to ignore, e.g. []string{"cancelled"}
+	IgnoreWords []string
+	// Locale is "US" or "UK", by default both spellings are accepted
+	Locale string
+	// Markdown also checks *.md files
+	Markdown bool
+	// Fix corrects the findings in place, refused when running in CI (CI=true)
+	Fix bool
+}
+
+// Misspell checks the project files for commonly misspelled english words.
+// Generated go files are skipped.
+//
+// Example:
+//     commands.Misspell(".", commands.MisspellConfig{Locale: "US", Markdown: true})
+func Misspell(dir string, cfg MisspellConfig) error {
+	if cfg.Fix && util.IsCI() {
+		return errors.New("misspell: refusing to fix files in CI")
+	}
+	version := cfg.Version
+	if version == "" {
+		version = MisspellVersion
+	}
+	misspellBin, err := EnsureTool(misspellImportPath, version)
+	if err != nil {
+		fmt.Println("misspell: tool not found")
+		return err
+	}
+
+	files, err := misspellFiles(cfg)
+	if err != nil {
+		fmt.Printf("misspell: error listing files: %s\n", err)
+		return err
+	}
+	if len(files) == 0 {
+		fmt.Println("misspell: no files to check")
+		return nil
+	}
+
+	out, err := sh.Output(misspellBin, misspellArgs(cfg, files)...)
+	if err != nil {
+		fmt.Printf("misspell: error executing %s\n", err)
+		return err
+	}
+	findings := nonEmpty(strings.Split(out, "\n"))
+	if len(findings) == 0 {
+		fmt.Println("misspell: no misspellings found")
+		return nil
+	}
+	if cfg.Fix {
+		fmt.Println("misspell: corrected the following misspellings:")
+		fmt.Println(out)
+		return nil
+	}
+	fmt.Println("misspell: the following misspellings were found:")
+	fmt.Println(out)
+	return errors.Errorf("misspell: %d misspelling(s) found:\n%s", len(findings), strings.Join(findings, "\n"))
+}
+
+func misspellArgs(cfg MisspellConfig, files []string) []string {
+	args := []string{}
+	if cfg.Locale != "" {
+		args = append(args, "-locale", cfg.Locale)
+	}
+	if len(cfg.IgnoreWords) != 0 {
+		args = append(args, "-i", strings.Join(cfg.IgnoreWords, ","))
+	}
+	if cfg.Fix {
+		args = append(args, "-w")
+	}
+	return append(args, files...)
+}
+
+// misspellFiles returns the non generated go files of the project, and markdown files when enabled
+func misspellFiles(cfg MisspellConfig) ([]string, error) {
+	var allExcludes []string
+	allExcludes = append(allExcludes, cfg.Excludes...)
+	allExcludes = append(allExcludes, util.GoLintExcludes()...)
+	dirs, err := util.GetProjectDirectoriesWithRoot(allExcludes)
+	if err != nil {
+		return nil, err
+	}
+	goFiles, err := util.GetGoFiles(dirs)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(goFiles))
+	for _, f := range goFiles {
+		src, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if !generatedRegex.Match(src) {
+			files = append(files, f)
+		}
+	}
+	if cfg.Markdown {
+		for _, dir := range dirs {
+			md, err := filepath.Glob(filepath.Join(dir, "*.md"))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, md...)
+		}
+	}
+	return files, nil
+}
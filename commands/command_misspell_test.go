@@ -0,0 +1,153 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMisspellArgs(t *testing.T) {
+	files := []string{"main.go", "README.md"}
+	tests := []struct {
+		name string
+		cfg  MisspellConfig
+		want []string
+	}{
+		{
+			name: "defaults",
+			cfg:  MisspellConfig{},
+			want: []string{"main.go", "README.md"},
+		},
+		{
+			name: "locale",
+			cfg:  MisspellConfig{Locale: "UK"},
+			want: []string{"-locale", "UK", "main.go", "README.md"},
+		},
+		{
+			name: "ignore words",
+			cfg:  MisspellConfig{IgnoreWords: []string{"cancelled", "marshalling"}},
+			want: []string{"-i", "cancelled,marshalling", "main.go", "README.md"},
+		},
+		{
+			name: "all options",
+			cfg:  MisspellConfig{Locale: "US", IgnoreWords: []string{"cancelled"}, Fix: true},
+			want: []string{"-locale", "US", "-i", "cancelled", "-w", "main.go", "README.md"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := misspellArgs(tt.cfg, files); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("misspellArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMisspellFiles(t *testing.T) {
+	files := map[string]string{
+		"main.go":          "package main\n",
+		"README.md":        "# fixture\n",
+		"a/a.go":           "package a\n",
+		"a/a.pb.go":        "// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage a\n",
+		"a/notes.md":       "notes\n",
+		"docs/docs.go":     "package docs\n",
+		"vendor/v/v.go":    "package v\n",
+		"a/generated.go":   "package a\n\n// Code generated by hand, still checked.\n",
+		"b/mentions.go":    "package b\n\n// This file is not // Code generated .* DO NOT EDIT.\n",
+		"b/misspelled.txt": "recieve\n",
+	}
+	tests := []struct {
+		name string
+		cfg  MisspellConfig
+		want []string
+	}{
+		{
+			name: "go files",
+			cfg:  MisspellConfig{Excludes: []string{"docs"}},
+			want: []string{
+				"main.go",
+				filepath.Join("a", "a.go"),
+				filepath.Join("a", "generated.go"),
+				filepath.Join("b", "mentions.go"),
+			},
+		},
+		{
+			name: "markdown",
+			cfg:  MisspellConfig{Excludes: []string{"docs"}, Markdown: true},
+			want: []string{
+				"main.go",
+				filepath.Join("a", "a.go"),
+				filepath.Join("a", "generated.go"),
+				filepath.Join("b", "mentions.go"),
+				"README.md",
+				filepath.Join("a", "notes.md"),
+			},
+		},
+	}
+	inFixtureModule(t, files, func(dir string) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got, err := misspellFiles(tt.cfg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("misspellFiles() = %q, want %q", got, tt.want)
+				}
+			})
+		}
+	})
+}
+
+func TestMisspell(t *testing.T) {
+	requireTool(t, "misspell")
+	files := map[string]string{
+		"main.go": "package main\n\n// We recieve the message.\nfunc main() {}\n",
+		"a/a.go":  "package a\n\n// It occured once.\n",
+	}
+	inFixtureModule(t, files, func(dir string) {
+		err := Misspell(".", MisspellConfig{})
+		if err == nil {
+			t.Fatal("Misspell() expected an error for the planted misspellings")
+		}
+		for _, want := range []string{"main.go:3:", `"receive"`, filepath.Join("a", "a.go") + ":3:", `"occurred"`} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("Misspell() error = %v, want it to contain %s", err, want)
+			}
+		}
+
+		defer setEnv(t, "CI", "true")()
+		if err := Misspell(".", MisspellConfig{Fix: true}); err == nil || !strings.Contains(err.Error(), "refusing to fix") {
+			t.Errorf("Misspell() with Fix in CI error = %v, want the fix refused", err)
+		}
+		if src, _ := ioutil.ReadFile("main.go"); string(src) != files["main.go"] {
+			t.Errorf("main.go was modified in CI:\n%s", src)
+		}
+
+		os.Unsetenv("CI")
+		if err := Misspell(".", MisspellConfig{Fix: true}); err != nil {
+			t.Fatalf("Misspell() with Fix error = %v", err)
+		}
+		src, err := ioutil.ReadFile("main.go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "package main\n\n// We receive the message.\nfunc main() {}\n"; string(src) != want {
+			t.Errorf("main.go after fixing =\n%s\nwant\n%s", src, want)
+		}
+		if err := Misspell(".", MisspellConfig{}); err != nil {
+			t.Errorf("Misspell() after fixing error = %v", err)
+		}
+	})
+}
@@ -0,0 +1,238 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/magefile/mage/sh"
+	"github.com/pkg/errors"
+
+	"github.com/zolia/go-ci/util"
+)
+
+const staticcheckImportPath = "honnef.co/go/tools/cmd/staticcheck"
+
+// StaticcheckVersion is the default pinned staticcheck version
+var StaticcheckVersion = "v0.4.7"
+
+// StaticcheckConfig configures Staticcheck
+type StaticcheckConfig struct {
+	// Version of staticcheck to install when missing, defaults to StaticcheckVersion
+	Version string
+	// Excludes are packages to skip, matched the same way as in the other checks
+	Excludes []string
+	// Checks selects the checks to run, e.g. []string{"all", "-ST1000"}
+	Checks []string
+	// Tags are build tags to use
+	Tags []string
+	// OutputFormat is either "json" or "sarif", written to OutputFile. Staticcheck runs once and the console report is built from it.
+	OutputFormat string
+	// OutputFile is where the OutputFormat report is written, e.g. for code scanning upload
+	OutputFile string
+}
+
+// staticcheckFinding is a single line of `staticcheck -f json` output
+type staticcheckFinding struct {
+	Code     string `json:"code"`
+	Severity string `json:"severity"`
+	Location struct {
+		File   string `json:"file"`
+		Line   int    `json:"line"`
+		Column int    `json:"column"`
+	} `json:"location"`
+	Message string `json:"message"`
+}
+
+func (f staticcheckFinding) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s (%s)", f.Location.File, f.Location.Line, f.Location.Column, f.Message, f.Code)
+}
+
+const staticcheckFindingsInError = 5
+
+// Staticcheck runs staticcheck over the packages found in dir
+//
+// Example:
+//     commands.Staticcheck(".", commands.StaticcheckConfig{Checks: []string{"all", "-ST1000"}, OutputFormat: "sarif", OutputFile: "build/staticcheck.sarif"})
+func Staticcheck(dir string, cfg StaticcheckConfig) error {
+	if cfg.OutputFormat != "" && cfg.OutputFormat != "json" && cfg.OutputFormat != "sarif" {
+		return errors.Errorf("staticcheck: unsupported output format %q", cfg.OutputFormat)
+	}
+	if (cfg.OutputFormat == "") != (cfg.OutputFile == "") {
+		return errors.New("staticcheck: output format and output file must be set together")
+	}
+	version := cfg.Version
+	if version == "" {
+		version = StaticcheckVersion
+	}
+	staticcheckBin, err := EnsureTool(staticcheckImportPath, version)
+	if err != nil {
+		fmt.Println("staticcheck: tool not found")
+		return err
+	}
+
+	pattern := path.Join(dir, "...")
+	if !path.IsAbs(pattern) {
+		pattern = "./" + pattern
+	}
+	packages, err := util.GetPackagePathsWithExcludes(pattern, cfg.Excludes...)
+	if err != nil {
+		fmt.Printf("staticcheck: go list crashed: %s\n", err)
+		return err
+	}
+	packages = nonEmpty(packages)
+	if len(packages) == 0 {
+		fmt.Println("staticcheck: no packages to check")
+		return nil
+	}
+
+	format := cfg.OutputFormat
+	if format == "" {
+		format = "json"
+	}
+	out, err := runStaticcheck(staticcheckBin, format, cfg, packages)
+	if err != nil {
+		return err
+	}
+	var findings []staticcheckFinding
+	if format == "sarif" {
+		findings, err = parseStaticcheckSARIF(out)
+	} else {
+		findings, err = parseStaticcheckJSON(bytes.NewReader(out))
+	}
+	if err != nil {
+		fmt.Printf("staticcheck: could not parse output: %s\n", err)
+		return err
+	}
+	if cfg.OutputFile != "" {
+		if err := ioutil.WriteFile(cfg.OutputFile, out, 0644); err != nil {
+			return err
+		}
+		fmt.Printf("staticcheck: %s report written to %s\n", cfg.OutputFormat, cfg.OutputFile)
+	}
+
+	if len(findings) != 0 {
+		fmt.Println("staticcheck: the following issues were found:")
+		for _, f := range findings {
+			fmt.Println(f)
+		}
+		first := findings
+		if len(first) > staticcheckFindingsInError {
+			first = first[:staticcheckFindingsInError]
+		}
+		lines := make([]string, len(first))
+		for i := range first {
+			lines[i] = first[i].String()
+		}
+		return errors.Errorf("staticcheck: %d issue(s) found, first ones:\n%s", len(findings), strings.Join(lines, "\n"))
+	}
+	fmt.Println("staticcheck: no issues found")
+	return nil
+}
+
+// runStaticcheck runs staticcheck with the given output format and returns its stdout.
+// Exit code 1 means findings were reported, anything above or failing to start is an operational failure.
+func runStaticcheck(bin, format string, cfg StaticcheckConfig, packages []string) ([]byte, error) {
+	args := []string{"-f", format}
+	if len(cfg.Checks) != 0 {
+		args = append(args, "-checks", strings.Join(cfg.Checks, ","))
+	}
+	if len(cfg.Tags) != 0 {
+		args = append(args, "-tags", strings.Join(cfg.Tags, ","))
+	}
+	args = append(args, packages...)
+
+	var stdout, stderr bytes.Buffer
+	ran, err := sh.Exec(nil, &stdout, &stderr, bin, args...)
+	if !ran || sh.ExitStatus(err) > 1 {
+		fmt.Print(stderr.String())
+		fmt.Printf("staticcheck: error executing %s\n", err)
+		return nil, err
+	}
+	if _, err := os.Stderr.Write(stderr.Bytes()); err != nil {
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+func parseStaticcheckJSON(r io.Reader) ([]staticcheckFinding, error) {
+	var findings []staticcheckFinding
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var f staticcheckFinding
+		if err := json.Unmarshal(line, &f); err != nil {
+			return nil, err
+		}
+		findings = append(findings, f)
+	}
+	return findings, scanner.Err()
+}
+
+type staticcheckSARIF struct {
+	Runs []struct {
+		Results []struct {
+			RuleID  string `json:"ruleId"`
+			Level   string `json:"level"`
+			Message struct {
+				Text string `json:"text"`
+			} `json:"message"`
+			Locations []struct {
+				PhysicalLocation struct {
+					ArtifactLocation struct {
+						URI string `json:"uri"`
+					} `json:"artifactLocation"`
+					Region struct {
+						StartLine   int `json:"startLine"`
+						StartColumn int `json:"startColumn"`
+					} `json:"region"`
+				} `json:"physicalLocation"`
+			} `json:"locations"`
+			Suppressions []json.RawMessage `json:"suppressions"`
+		} `json:"results"`
+	} `json:"runs"`
+}
+
+// parseStaticcheckSARIF reads `staticcheck -f sarif` output.
+// Problems silenced with lint:ignore are reported as suppressed results and skipped, matching the json output.
+func parseStaticcheckSARIF(out []byte) ([]staticcheckFinding, error) {
+	var report staticcheckSARIF
+	if err := json.Unmarshal(out, &report); err != nil {
+		return nil, err
+	}
+	var findings []staticcheckFinding
+	for _, run := range report.Runs {
+		for _, r := range run.Results {
+			if len(r.Suppressions) != 0 {
+				continue
+			}
+			f := staticcheckFinding{Code: r.RuleID, Severity: r.Level, Message: r.Message.Text}
+			if len(r.Locations) != 0 {
+				loc := r.Locations[0].PhysicalLocation
+				f.Location.File = strings.TrimPrefix(loc.ArtifactLocation.URI, "file://")
+				f.Location.Line = loc.Region.StartLine
+				f.Location.Column = loc.Region.StartColumn
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings, nil
+}
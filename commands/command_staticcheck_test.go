@@ -0,0 +1,98 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const staticcheckSARIFOutput = `{
+  "version": "2.1.0",
+  "runs": [{
+    "tool": {"driver": {"name": "Staticcheck"}},
+    "results": [
+      {
+        "ruleId": "SA4006",
+        "level": "error",
+        "message": {"text": "this value of x is never used"},
+        "locations": [{"physicalLocation": {
+          "artifactLocation": {"uri": "file:///src/a/a.go"},
+          "region": {"startLine": 7, "startColumn": 2}
+        }}]
+      },
+      {
+        "ruleId": "ST1003",
+        "level": "warning",
+        "message": {"text": "should not use underscores in Go names"},
+        "locations": [{"physicalLocation": {
+          "artifactLocation": {"uri": "b/b.go"},
+          "region": {"startLine": 3, "startColumn": 6}
+        }}]
+      },
+      {
+        "ruleId": "SA1019",
+        "level": "error",
+        "message": {"text": "ignored deprecation"},
+        "locations": [{"physicalLocation": {
+          "artifactLocation": {"uri": "file:///src/a/a.go"},
+          "region": {"startLine": 9, "startColumn": 1}
+        }}],
+        "suppressions": [{"kind": "inSource", "justification": "legacy"}]
+      }
+    ]
+  }]
+}`
+
+func TestParseStaticcheckSARIF(t *testing.T) {
+	findings, err := parseStaticcheckSARIF([]byte(staticcheckSARIFOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.String())
+	}
+	want := []string{
+		"/src/a/a.go:7:2: this value of x is never used (SA4006)",
+		"b/b.go:3:6: should not use underscores in Go names (ST1003)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseStaticcheckSARIF() = %q, want %q", got, want)
+	}
+}
+
+func TestParseStaticcheckSARIFMalformed(t *testing.T) {
+	if _, err := parseStaticcheckSARIF([]byte("-: not sarif")); err == nil {
+		t.Error("parseStaticcheckSARIF() expected an error for malformed output")
+	}
+}
+
+func TestParseStaticcheckJSON(t *testing.T) {
+	out := `{"code":"SA4006","severity":"error","location":{"file":"/src/a/a.go","line":7,"column":2},"message":"this value of x is never used"}
+
+{"code":"S1000","severity":"error","location":{"file":"/src/b/b.go","line":4,"column":1},"message":"should use for range"}
+`
+	findings, err := parseStaticcheckJSON(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 2 || findings[1].String() != "/src/b/b.go:4:1: should use for range (S1000)" {
+		t.Errorf("parseStaticcheckJSON() = %v", findings)
+	}
+}
+
+func TestRunStaticcheckNotStarted(t *testing.T) {
+	bin := filepath.Join("testdata", "no-such-staticcheck")
+	if _, err := runStaticcheck(bin, "json", StaticcheckConfig{}, []string{"./..."}); err == nil {
+		t.Error("runStaticcheck() expected an error when the binary could not be started")
+	}
+}
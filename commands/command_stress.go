@@ -0,0 +1,215 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/magefile/mage/sh"
+	"github.com/pkg/errors"
+)
+
+// StressConfig describes a GoStress run
+type StressConfig struct {
+	// Package is the import path or relative path of the package under stress, e.g. "./service"
+	Package string
+	// Run is passed as -run to select the tests to repeat
+	Run string
+	// MaxRuns stops the stress after this many runs, 0 means no limit.
+	// When MaxDuration is zero too, it defaults to 100 so the stress always ends.
+	MaxRuns int
+	// MaxDuration stops starting new runs after this long, 0 means no limit
+	MaxDuration time.Duration
+	// Timeout limits every single run, it is passed to the test binary as -test.timeout
+	// and a run still alive shortly after it is killed. Both count as a failure.
+	// Defaults to MaxDuration when set, otherwise to 10 minutes like go test.
+	Timeout time.Duration
+	// Parallelism is the number of concurrently running test processes, defaults to 1
+	Parallelism int
+	// StopOnFailure stops starting new runs after the first failure
+	StopOnFailure bool
+	// Race enables the race detector
+	Race bool
+	// OutputDir is where the output of failing runs is saved, defaults to the system temp dir
+	OutputDir string
+}
+
+// StressResult summarizes a GoStress run
+type StressResult struct {
+	Runs     int
+	Failures int
+	Elapsed  time.Duration
+	// FailureLogs holds paths of the files containing output of the failed runs
+	FailureLogs []string
+}
+
+// String returns a one line summary of the stress run
+func (r StressResult) String() string {
+	return fmt.Sprintf("%d runs, %d failures, %s elapsed", r.Runs, r.Failures, r.Elapsed.Round(time.Second))
+}
+
+const (
+	defaultStressRuns    = 100
+	defaultStressTimeout = 10 * time.Minute
+	// stressKillGrace gives a timed out test binary time to print its goroutines before it is killed
+	stressKillGrace = 5 * time.Second
+)
+
+// GoStress repeatedly runs the selected tests of a package until one of the bounds is reached.
+// The test binary is built once and then executed over and over, which is much faster than calling go test in a loop.
+// Runs still in flight when MaxDuration is reached finish or time out within Timeout.
+//
+// Example:
+//     commands.GoStress(commands.StressConfig{Package: "./service", Run: "TestFlaky", MaxDuration: 10 * time.Minute, Parallelism: 4, Race: true})
+func GoStress(cfg StressConfig) (StressResult, error) {
+	if cfg.Package == "" {
+		return StressResult{}, errors.New("stress: package is required")
+	}
+	if cfg.MaxRuns == 0 && cfg.MaxDuration == 0 {
+		cfg.MaxRuns = defaultStressRuns
+	}
+	if cfg.Parallelism < 1 {
+		cfg.Parallelism = 1
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = cfg.MaxDuration
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultStressTimeout
+	}
+
+	pkgDir, err := sh.Output("go", "list", "-f", "{{.Dir}}", cfg.Package)
+	if err != nil {
+		fmt.Printf("stress: could not resolve package %s: %s\n", cfg.Package, err)
+		return StressResult{}, err
+	}
+	tmpDir, err := ioutil.TempDir("", "stress")
+	if err != nil {
+		return StressResult{}, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	binary := filepath.Join(tmpDir, "stress.test")
+	args := []string{"test", "-c", "-o", binary}
+	if cfg.Race {
+		args = append(args, "-race")
+	}
+	args = append(args, cfg.Package)
+	if err := sh.RunV("go", args...); err != nil {
+		fmt.Printf("stress: could not build test binary: %s\n", err)
+		return StressResult{}, err
+	}
+	if _, err := os.Stat(binary); err != nil {
+		return StressResult{}, errors.Errorf("stress: package %s has no tests", cfg.Package)
+	}
+
+	testArgs := []string{"-test.count=1", "-test.timeout=" + cfg.Timeout.String()}
+	if cfg.Run != "" {
+		testArgs = append(testArgs, "-test.run="+cfg.Run)
+	}
+
+	s := &stress{cfg: cfg, started: time.Now()}
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s.next() {
+				s.done(runStress(binary, pkgDir, testArgs, cfg.Timeout+stressKillGrace))
+			}
+		}()
+	}
+	wg.Wait()
+
+	s.result.Elapsed = time.Since(s.started)
+	fmt.Printf("\nstress: finished: %s\n", s.result)
+	if s.result.Failures != 0 {
+		return s.result, errors.Errorf("stress: %d of %d runs failed, output saved to:\n%s",
+			s.result.Failures, s.result.Runs, strings.Join(s.result.FailureLogs, "\n"))
+	}
+	return s.result, nil
+}
+
+// runStress runs the test binary once, killing it when it is still alive after the timeout
+func runStress(binary, dir string, args []string, timeout time.Duration) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		out = append(out, fmt.Sprintf("\nstress: run killed after %s\n", timeout)...)
+		err = errors.Errorf("stress: run killed after %s", timeout)
+	}
+	return out, err
+}
+
+type stress struct {
+	cfg     StressConfig
+	started time.Time
+
+	mu       sync.Mutex
+	launched int
+	result   StressResult
+}
+
+// next reserves the next run, returning false once any of the bounds is reached
+func (s *stress) next() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cfg.MaxRuns > 0 && s.launched >= s.cfg.MaxRuns {
+		return false
+	}
+	if s.cfg.MaxDuration > 0 && time.Since(s.started) >= s.cfg.MaxDuration {
+		return false
+	}
+	if s.cfg.StopOnFailure && s.result.Failures > 0 {
+		return false
+	}
+	s.launched++
+	return true
+}
+
+func (s *stress) done(out []byte, runErr error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.result.Runs++
+	if runErr != nil {
+		s.result.Failures++
+		logPath, err := s.saveOutput(out)
+		if err != nil {
+			fmt.Printf("stress: could not save output of failed run: %s\n", err)
+		} else {
+			s.result.FailureLogs = append(s.result.FailureLogs, logPath)
+			fmt.Printf("\nstress: run %d failed, output saved to %s\n", s.result.Runs, logPath)
+		}
+	}
+	s.result.Elapsed = time.Since(s.started)
+	fmt.Printf("\rstress: %s", s.result)
+}
+
+func (s *stress) saveOutput(out []byte) (string, error) {
+	f, err := ioutil.TempFile(s.cfg.OutputDir, "stress-failure-*.log")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(out); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,124 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stressFixture returns a fixture module with a test of the given body in package stress
+func stressFixture(body string) map[string]string {
+	return map[string]string{
+		"stress/stress.go":      "package stress\n",
+		"stress/stress_test.go": "package stress\n\nimport (\n\t\"testing\"\n\t\"time\"\n)\n\nvar _ = time.Second\n\nfunc TestStress(t *testing.T) {\n" + body + "\n}\n",
+	}
+}
+
+func TestGoStressMaxRuns(t *testing.T) {
+	inFixtureModule(t, stressFixture(""), func(dir string) {
+		result, err := GoStress(StressConfig{Package: "./stress", MaxRuns: 5, Parallelism: 2})
+		if err != nil {
+			t.Fatalf("GoStress() error = %v", err)
+		}
+		if result.Runs != 5 || result.Failures != 0 {
+			t.Errorf("GoStress() = %s, want 5 runs without failures", result)
+		}
+	})
+}
+
+func TestGoStressMaxDuration(t *testing.T) {
+	inFixtureModule(t, stressFixture("\ttime.Sleep(50 * time.Millisecond)"), func(dir string) {
+		const maxDuration = 500 * time.Millisecond
+		result, err := GoStress(StressConfig{Package: "./stress", MaxDuration: maxDuration})
+		if err != nil {
+			t.Fatalf("GoStress() error = %v", err)
+		}
+		if result.Runs == 0 || result.Runs > int(maxDuration/(50*time.Millisecond)) {
+			t.Errorf("GoStress() = %s, want at most %d runs", result, maxDuration/(50*time.Millisecond))
+		}
+		// the last run may start right before MaxDuration is reached
+		if result.Elapsed > maxDuration+5*time.Second {
+			t.Errorf("GoStress() ran for %s, want it bounded by MaxDuration", result.Elapsed)
+		}
+	})
+}
+
+func TestGoStressStopOnFailure(t *testing.T) {
+	inFixtureModule(t, stressFixture("\tt.Fatal(\"flaky\")"), func(dir string) {
+		outputDir, err := ioutil.TempDir("", "stress-output")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(outputDir)
+
+		const parallelism = 4
+		result, err := GoStress(StressConfig{Package: "./stress", MaxRuns: 100, Parallelism: parallelism, StopOnFailure: true, OutputDir: outputDir})
+		if err == nil {
+			t.Fatal("GoStress() expected an error for failing runs")
+		}
+		// runs already started by the other workers still finish
+		if result.Failures == 0 || result.Runs > parallelism {
+			t.Errorf("GoStress() = %s, want at most %d runs", result, parallelism)
+		}
+		if len(result.FailureLogs) != result.Failures {
+			t.Fatalf("GoStress() saved %d logs for %d failures", len(result.FailureLogs), result.Failures)
+		}
+		out, err := ioutil.ReadFile(result.FailureLogs[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(out), "flaky") {
+			t.Errorf("failure log = %q, want the test output", out)
+		}
+	})
+}
+
+func TestGoStressTimeout(t *testing.T) {
+	inFixtureModule(t, stressFixture("\tselect {}"), func(dir string) {
+		outputDir, err := ioutil.TempDir("", "stress-output")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(outputDir)
+
+		result, err := GoStress(StressConfig{Package: "./stress", MaxRuns: 1, Timeout: 500 * time.Millisecond, OutputDir: outputDir})
+		if err == nil {
+			t.Fatal("GoStress() expected an error for a hung run")
+		}
+		if result.Runs != 1 || result.Failures != 1 || len(result.FailureLogs) != 1 {
+			t.Fatalf("GoStress() = %s with logs %v, want the hung run recorded as a failure", result, result.FailureLogs)
+		}
+		out, err := ioutil.ReadFile(result.FailureLogs[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(out), "test timed out") {
+			t.Errorf("failure log = %q, want the test timeout panic", out)
+		}
+	})
+}
+
+func TestRunStressKillsHungProcess(t *testing.T) {
+	// sleep ignores -test.timeout, so only the kill stops it
+	started := time.Now()
+	out, err := runStress("sleep", ".", []string{"10"}, 200*time.Millisecond)
+	if err == nil {
+		t.Fatal("runStress() expected an error for a killed run")
+	}
+	if !strings.Contains(string(out), "stress: run killed after 200ms") {
+		t.Errorf("runStress() output = %q, want the kill recorded", out)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("runStress() took %s, want the process killed", elapsed)
+	}
+}
@@ -0,0 +1,199 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/magefile/mage/sh"
+	"github.com/pkg/errors"
+)
+
+// TestChangedConfig configures GoTestChanged
+type TestChangedConfig struct {
+	// RunAllPatterns are regular expressions of changed file paths that run the whole suite,
+	// in addition to go.mod, go.sum and testdata files, e.g. `\.proto$`
+	RunAllPatterns []string
+	// Tags are build tags to use
+	Tags []string
+}
+
+// GoTestChanged runs the tests of the packages changed since baseRef and of all packages depending on them,
+// with the same flags as Test. Changed files are taken from `git diff --name-only <baseRef>...HEAD`.
+// Changes to go.mod, go.sum, testdata or files matching RunAllPatterns run the whole suite.
+//
+// Example:
+//     commands.GoTestChanged(commands.TestChangedConfig{RunAllPatterns: []string{`^ci/`}}, "origin/master")
+func GoTestChanged(cfg TestChangedConfig, baseRef string) error {
+	packages, err := changedPackages(cfg, baseRef)
+	if err != nil {
+		return err
+	}
+	if len(packages) == 0 {
+		fmt.Printf("test: no packages affected by changes since %s\n", baseRef)
+		return nil
+	}
+	fmt.Printf("test: %d package(s) affected by changes since %s:\n", len(packages), baseRef)
+	for _, p := range packages {
+		fmt.Println(p)
+	}
+
+	args := []string{"test", "-race", "-cover"}
+	if len(cfg.Tags) != 0 {
+		args = append(args, "-tags", strings.Join(cfg.Tags, ","))
+	}
+	return sh.RunV("go", append(args, packages...)...)
+}
+
+// changedPackages returns the import paths of the packages to test, or "./..." when everything has to run
+func changedPackages(cfg TestChangedConfig, baseRef string) ([]string, error) {
+	runAll := make([]*regexp.Regexp, 0, len(cfg.RunAllPatterns))
+	for _, pattern := range cfg.RunAllPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "test: invalid run all pattern %q", pattern)
+		}
+		runAll = append(runAll, re)
+	}
+
+	out, err := sh.Output("git", "diff", "--name-only", "--relative", baseRef+"...HEAD")
+	if err != nil {
+		fmt.Printf("test: could not list changes since %s: %s\n", baseRef, err)
+		return nil, err
+	}
+	changedDirs := make(map[string]bool)
+	for _, file := range nonEmpty(strings.Split(out, "\n")) {
+		if runsEverything(file, runAll) {
+			fmt.Printf("test: %s changed, running all packages\n", file)
+			return []string{"./..."}, nil
+		}
+		changedDirs[filepath.Dir(filepath.FromSlash(file))] = true
+	}
+	if len(changedDirs) == 0 {
+		return nil, nil
+	}
+
+	graph, err := listPackageGraph(cfg.Tags)
+	if err != nil {
+		return nil, err
+	}
+	var changed []string
+	for _, pkg := range graph {
+		if changedDirs[pkg.dir] {
+			changed = append(changed, pkg.importPath)
+		}
+	}
+	return affectedPackages(graph, changed), nil
+}
+
+func runsEverything(file string, runAll []*regexp.Regexp) bool {
+	switch filepath.Base(file) {
+	case "go.mod", "go.sum":
+		return true
+	}
+	if isTestdata(filepath.Dir(file)) {
+		return true
+	}
+	for _, re := range runAll {
+		if re.MatchString(file) {
+			return true
+		}
+	}
+	return false
+}
+
+type packageNode struct {
+	importPath string
+	// dir is relative to the working directory
+	dir string
+	// imports are the packages imported by the package itself
+	imports []string
+	// testImports are the packages imported by its tests only
+	testImports []string
+}
+
+// listPackageGraph lists the packages of the module with their imports
+func listPackageGraph(tags []string) ([]packageNode, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"list", "-e", "-f", "{{.ImportPath}}\t{{.Dir}}\t{{join .Imports \" \"}}\t{{join .TestImports \" \"}} {{join .XTestImports \" \"}}"}
+	if len(tags) != 0 {
+		args = append(args, "-tags", strings.Join(tags, ","))
+	}
+	out, err := sh.Output("go", append(args, "./...")...)
+	if err != nil {
+		fmt.Printf("test: go list crashed: %s\n", err)
+		return nil, err
+	}
+	var graph []packageNode
+	for _, line := range nonEmpty(strings.Split(out, "\n")) {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 {
+			return nil, errors.Errorf("test: unexpected go list output %q", line)
+		}
+		dir, err := filepath.Rel(wd, fields[1])
+		if err != nil {
+			return nil, err
+		}
+		graph = append(graph, packageNode{
+			importPath:  fields[0],
+			dir:         dir,
+			imports:     strings.Fields(fields[2]),
+			testImports: strings.Fields(fields[3]),
+		})
+	}
+	return graph, nil
+}
+
+// affectedPackages returns the changed packages, the packages importing them directly or transitively,
+// and the packages whose tests import any of those, sorted
+func affectedPackages(graph []packageNode, changed []string) []string {
+	importedBy := make(map[string][]string)
+	for _, pkg := range graph {
+		for _, imp := range pkg.imports {
+			importedBy[imp] = append(importedBy[imp], pkg.importPath)
+		}
+	}
+	affected := make(map[string]bool)
+	queue := append([]string(nil), changed...)
+	for len(queue) != 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+		if affected[pkg] {
+			continue
+		}
+		affected[pkg] = true
+		queue = append(queue, importedBy[pkg]...)
+	}
+
+	selected := make(map[string]bool, len(affected))
+	for pkg := range affected {
+		selected[pkg] = true
+	}
+	for _, pkg := range graph {
+		for _, imp := range pkg.testImports {
+			if affected[imp] {
+				selected[pkg.importPath] = true
+			}
+		}
+	}
+	var result []string
+	for pkg := range selected {
+		result = append(result, pkg)
+	}
+	sort.Strings(result)
+	return result
+}
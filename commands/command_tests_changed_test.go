@@ -0,0 +1,102 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestChangedPackages(t *testing.T) {
+	requireTool(t, "git")
+	files := map[string]string{
+		"a/a.go":        "package a\n\nconst A = 1\n",
+		"b/b.go":        "package b\n\nimport \"example.com/fixture/a\"\n\nconst B = a.A\n",
+		"c/c.go":        "package c\n\nimport \"example.com/fixture/b\"\n\nconst C = b.B\n",
+		"d/d.go":        "package d\n\nconst D = 1\n",
+		"e/e.go":        "package e\n",
+		"e/e_test.go":   "package e_test\n\nimport (\n\t\"testing\"\n\n\t\"example.com/fixture/a\"\n)\n\nfunc TestE(t *testing.T) { _ = a.A }\n",
+		"f/f.go":        "package f\n\nimport _ \"example.com/fixture/e\"\n",
+		"d/testdata/x":  "fixture\n",
+		"api/api.proto": "syntax = \"proto3\";\n",
+		"README.md":     "# fixture\n",
+	}
+	inFixtureModule(t, files, func(dir string) {
+		runGit(t, "init", "-q")
+		runGit(t, "add", ".")
+		runGit(t, "commit", "-q", "-m", "initial")
+		runGit(t, "tag", "base")
+
+		change := func(file, contents string) {
+			t.Helper()
+			runGit(t, "checkout", "-q", "-B", "change", "base")
+			if err := ioutil.WriteFile(file, []byte(contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+			runGit(t, "commit", "-q", "-am", "change "+file)
+		}
+		cfg := TestChangedConfig{RunAllPatterns: []string{`\.proto$`}}
+		tests := []struct {
+			name     string
+			file     string
+			contents string
+			want     []string
+		}{
+			{
+				name:     "leaf package",
+				file:     "a/a.go",
+				contents: "package a\n\nconst A = 2\n",
+				want:     []string{"example.com/fixture/a", "example.com/fixture/b", "example.com/fixture/c", "example.com/fixture/e"},
+			},
+			{
+				name:     "independent package",
+				file:     "d/d.go",
+				contents: "package d\n\nconst D = 2\n",
+				want:     []string{"example.com/fixture/d"},
+			},
+			{
+				name:     "non go file",
+				file:     "README.md",
+				contents: "# changed\n",
+				want:     nil,
+			},
+			{
+				name:     "go.mod",
+				file:     "go.mod",
+				contents: "module example.com/fixture\n\ngo 1.15\n",
+				want:     []string{"./..."},
+			},
+			{
+				name:     "testdata",
+				file:     "d/testdata/x",
+				contents: "changed\n",
+				want:     []string{"./..."},
+			},
+			{
+				name:     "run all pattern",
+				file:     "api/api.proto",
+				contents: "syntax = \"proto3\";\n\npackage api;\n",
+				want:     []string{"./..."},
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				change(tt.file, tt.contents)
+				got, err := changedPackages(cfg, "base")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("changedPackages() = %q, want %q", got, tt.want)
+				}
+			})
+		}
+	})
+}
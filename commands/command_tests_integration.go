@@ -0,0 +1,193 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/magefile/mage/sh"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultReadyTimeout = time.Minute
+	readyPollInterval   = 250 * time.Millisecond
+)
+
+// ReadyCheck waits for a service of the compose stack, either by Address or by URL
+type ReadyCheck struct {
+	// Name identifies the service in messages, e.g. "postgres"
+	Name string
+	// Address is ready once it accepts TCP connections, e.g. "localhost:5432"
+	Address string
+	// URL is ready once a GET request returns a 2xx status, e.g. "http://localhost:8080/health"
+	URL string
+	// Timeout to wait for the service, defaults to 1 minute
+	Timeout time.Duration
+}
+
+func (c ReadyCheck) String() string {
+	target := c.Address
+	if c.URL != "" {
+		target = c.URL
+	}
+	if c.Name == "" {
+		return target
+	}
+	return c.Name + " (" + target + ")"
+}
+
+// IntegrationConfig configures GoTestIntegration
+type IntegrationConfig struct {
+	// Packages to test, defaults to "./..."
+	Packages []string
+	// Tags are build tags to use, defaults to "integration"
+	Tags []string
+	// Env is added to the test environment, e.g. service addresses
+	Env map[string]string
+	// ComposeFile is the compose file of the services, defaults to "docker-compose.yml"
+	ComposeFile string
+	// ComposeProject is the compose project name, by default compose derives it from the directory
+	ComposeProject string
+	// ReadyChecks are waited for after the stack is up, before the tests start
+	ReadyChecks []ReadyCheck
+	// KeepUp leaves the stack running after the tests, e.g. to debug failures
+	KeepUp bool
+}
+
+// GoTestIntegration brings the compose stack up, waits for the services to be ready, runs the tests with the
+// integration build tag and tears the stack down again, also when the tests fail.
+//
+// Example:
+//     commands.GoTestIntegration(commands.IntegrationConfig{
+//         ComposeFile: "e2e/docker-compose.yml",
+//         ReadyChecks: []commands.ReadyCheck{{Name: "postgres", Address: "localhost:5432"}, {Name: "api", URL: "http://localhost:8080/health"}},
+//         Env:         map[string]string{"POSTGRES_ADDR": "localhost:5432"},
+//     })
+func GoTestIntegration(cfg IntegrationConfig) (err error) {
+	for _, check := range cfg.ReadyChecks {
+		if (check.Address == "") == (check.URL == "") {
+			return errors.Errorf("integration: ready check %s needs either an address or a URL", check)
+		}
+	}
+	compose, err := composeCommand()
+	if err != nil {
+		return err
+	}
+	composeFile := cfg.ComposeFile
+	if composeFile == "" {
+		composeFile = "docker-compose.yml"
+	}
+	composeArgs := append(append([]string{}, compose[1:]...), "-f", composeFile)
+	if cfg.ComposeProject != "" {
+		composeArgs = append(composeArgs, "-p", cfg.ComposeProject)
+	}
+	runCompose := func(args ...string) error {
+		return sh.RunV(compose[0], append(append([]string{}, composeArgs...), args...)...)
+	}
+
+	fmt.Printf("integration: starting services from %s\n", composeFile)
+	if err := runCompose("up", "-d"); err != nil {
+		fmt.Printf("integration: could not start services: %s\n", err)
+		// a partially started stack still needs to be removed
+		if downErr := runCompose("down", "--remove-orphans"); downErr != nil {
+			fmt.Printf("integration: could not stop services: %s\n", downErr)
+		}
+		return err
+	}
+	defer func() {
+		if cfg.KeepUp {
+			fmt.Printf("integration: leaving services of %s running\n", composeFile)
+			return
+		}
+		fmt.Println("integration: stopping services")
+		if downErr := runCompose("down", "--remove-orphans"); downErr != nil {
+			fmt.Printf("integration: could not stop services: %s\n", downErr)
+			if err == nil {
+				err = downErr
+			}
+		}
+	}()
+
+	for _, check := range cfg.ReadyChecks {
+		if err := waitReady(check); err != nil {
+			fmt.Println(err)
+			return err
+		}
+		fmt.Printf("integration: %s is ready\n", check)
+	}
+
+	tags := cfg.Tags
+	if len(tags) == 0 {
+		tags = []string{"integration"}
+	}
+	packages := cfg.Packages
+	if len(packages) == 0 {
+		packages = []string{"./..."}
+	}
+	args := append([]string{"test", "-count=1", "-tags", strings.Join(tags, ",")}, packages...)
+	_, err = sh.Exec(cfg.Env, os.Stdout, os.Stderr, "go", args...)
+	return err
+}
+
+// composeCommand returns the compose command available, preferring the docker compose plugin over docker-compose
+func composeCommand() ([]string, error) {
+	if _, err := sh.Output("docker", "compose", "version"); err == nil {
+		return []string{"docker", "compose"}, nil
+	}
+	if _, err := sh.Output("docker-compose", "version"); err == nil {
+		return []string{"docker-compose"}, nil
+	}
+	return nil, errors.New("integration: docker compose is not available, install Docker with the compose plugin or docker-compose")
+}
+
+// waitReady polls the check until it succeeds or its timeout expires
+func waitReady(check ReadyCheck) error {
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = defaultReadyTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		err := probe(check, readyPollInterval)
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.Wrapf(err, "integration: %s not ready after %s", check, timeout)
+		}
+		time.Sleep(readyPollInterval)
+	}
+}
+
+// probe checks the service once
+func probe(check ReadyCheck, timeout time.Duration) error {
+	if check.Address != "" {
+		conn, err := net.DialTimeout("tcp", check.Address, timeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	client := http.Client{Timeout: timeout}
+	resp, err := client.Get(check.URL)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWaitReadyTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+
+	if err := waitReady(ReadyCheck{Name: "db", Address: addr, Timeout: time.Second}); err != nil {
+		t.Errorf("waitReady() on a listening port error = %v", err)
+	}
+
+	l.Close()
+	err = waitReady(ReadyCheck{Name: "db", Address: addr, Timeout: 500 * time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "db ("+addr+") not ready after 500ms") {
+		t.Errorf("waitReady() on a closed port error = %v, want a timeout", err)
+	}
+}
+
+func TestWaitReadyTCPListenerStartsLate(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	started := make(chan net.Listener, 1)
+	go func() {
+		time.Sleep(600 * time.Millisecond)
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			started <- nil
+			return
+		}
+		started <- l
+	}()
+	err = waitReady(ReadyCheck{Address: addr, Timeout: 10 * time.Second})
+	if l := <-started; l == nil {
+		t.Skip("could not listen on the same port again")
+	} else {
+		defer l.Close()
+	}
+	if err != nil {
+		t.Errorf("waitReady() error = %v, want the late listener found", err)
+	}
+}
+
+func TestWaitReadyHTTP(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	if err := waitReady(ReadyCheck{URL: srv.URL, Timeout: 10 * time.Second}); err != nil {
+		t.Errorf("waitReady() error = %v", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("waitReady() sent %d requests, want 3", n)
+	}
+}
+
+func TestWaitReadyHTTPTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	err := waitReady(ReadyCheck{Name: "api", URL: srv.URL, Timeout: 500 * time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "500 Internal Server Error") {
+		t.Errorf("waitReady() error = %v, want the last status reported", err)
+	}
+}
+
+func TestGoTestIntegrationValidation(t *testing.T) {
+	err := GoTestIntegration(IntegrationConfig{ReadyChecks: []ReadyCheck{{Name: "db"}}})
+	if err == nil || !strings.Contains(err.Error(), "needs either an address or a URL") {
+		t.Errorf("GoTestIntegration() error = %v, want the invalid check reported", err)
+	}
+
+	emptyPath, err := ioutil.TempDir("", "empty-path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(emptyPath)
+	defer setEnv(t, "PATH", emptyPath)()
+	err = GoTestIntegration(IntegrationConfig{})
+	if err == nil || !strings.Contains(err.Error(), "docker compose is not available") {
+		t.Errorf("GoTestIntegration() without docker error = %v, want compose reported missing", err)
+	}
+}
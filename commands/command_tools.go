@@ -0,0 +1,165 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/magefile/mage/sh"
+	"github.com/pkg/errors"
+
+	"github.com/zolia/go-ci/util"
+)
+
+// ToolsBinDir is where EnsureTool installs missing tools, e.g. "./bin" to keep them project local so CI can cache them.
+// When set, tools are only looked up there and reinstalled when built at another version than pinned.
+// Empty means the go default ($GOBIN or $GOPATH/bin).
+var ToolsBinDir = ""
+
+// Pinned versions of the tools installed by the commands
+var (
+	GoImportsVersion = "v0.1.12"
+	GoLintVersion    = "v0.0.0-20210508222113-6edffad5e616"
+)
+
+const (
+	goImportsImportPath = "golang.org/x/tools/cmd/goimports"
+	goLintImportPath    = "golang.org/x/lint/golint"
+)
+
+var majorVersionRegex = regexp.MustCompile(`^v[0-9]+$`)
+
+// EnsureTool returns the path of the binary built from importPath, installing it with `go install importPath@version` when missing.
+//
+// When ToolsBinDir is set only that directory is used and a binary built at another version is reinstalled.
+// Otherwise the tool is looked up in PATH and $GOPATH/bin, and a version mismatch is only reported.
+//
+// Example:
+//     commands.EnsureTool("golang.org/x/tools/cmd/goimports", "v0.1.12")
+func EnsureTool(importPath, version string) (string, error) {
+	name := toolBinaryName(importPath)
+	if binPath, ok := findTool(name); ok {
+		installed := toolVersion(binPath, importPath)
+		if version == "latest" || installed == version {
+			return binPath, nil
+		}
+		if installed == "" {
+			installed = "an unknown version"
+		}
+		if ToolsBinDir == "" {
+			fmt.Printf("Tool '%s' is %s, pinned version is %s: using %s\n", name, installed, version, binPath)
+			return binPath, nil
+		}
+		fmt.Printf("Tool '%s' is %s, reinstalling %s\n", name, installed, version)
+		// go install refuses to overwrite files it did not build
+		if err := os.Remove(binPath); err != nil {
+			return "", errors.Wrapf(err, "could not remove tool '%s'", name)
+		}
+	}
+
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		return "", errors.Wrap(err, "could not find go binary")
+	}
+	env := map[string]string{}
+	if ToolsBinDir != "" {
+		binDir, err := filepath.Abs(ToolsBinDir)
+		if err != nil {
+			return "", err
+		}
+		env["GOBIN"] = binDir
+	}
+	fmt.Printf("Installing tool '%s' %s\n", name, version)
+	if err := sh.RunWith(env, goBin, "install", importPath+"@"+version); err != nil {
+		fmt.Printf("Could not install %s: %s\n", importPath, err)
+		return "", err
+	}
+
+	binPath, ok := findTool(name)
+	if !ok {
+		return "", errors.Errorf("tool '%s' installed but not found", name)
+	}
+	return binPath, nil
+}
+
+// findTool looks for the binary in ToolsBinDir when it is set, otherwise in PATH and $GOPATH/bin
+func findTool(name string) (string, bool) {
+	if ToolsBinDir != "" {
+		binPath := filepath.Join(ToolsBinDir, name)
+		if runtime.GOOS == "windows" {
+			binPath += ".exe"
+		}
+		if _, err := os.Stat(binPath); err != nil {
+			return "", false
+		}
+		absPath, err := filepath.Abs(binPath)
+		return absPath, err == nil
+	}
+	binPath, err := util.GetGoBinaryPath(name)
+	if err != nil || binPath == "" {
+		return "", false
+	}
+	return binPath, true
+}
+
+// toolVersion returns the version of the module importPath was built from, as recorded in the binary.
+// Empty means the binary carries no usable build info.
+func toolVersion(binPath, importPath string) string {
+	out, err := sh.Output("go", "version", "-m", binPath)
+	if err != nil {
+		return ""
+	}
+	return parseToolVersion(out, importPath)
+}
+
+// parseToolVersion finds the version of the module providing importPath in `go version -m` output.
+// The tool can be the main module ("mod" line) or a dependency of it ("dep" line).
+func parseToolVersion(out, importPath string) string {
+	var modPath, version string
+	selected := false
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "mod", "dep":
+			selected = false
+			p := fields[1]
+			if (importPath == p || strings.HasPrefix(importPath, p+"/")) && len(p) > len(modPath) && len(fields) >= 3 {
+				modPath, version, selected = p, fields[2], true
+			}
+		case "=>":
+			// a replaced module is not the pinned release
+			if selected {
+				version = ""
+			}
+		}
+	}
+	if version == "(devel)" {
+		return ""
+	}
+	return version
+}
+
+// toolBinaryName returns the name of the binary go install produces for importPath, skipping major version suffixes
+func toolBinaryName(importPath string) string {
+	name := path.Base(importPath)
+	if majorVersionRegex.MatchString(name) {
+		name = path.Base(path.Dir(importPath))
+	}
+	return name
+}
@@ -0,0 +1,228 @@
+/*
+ *  Copyright (c) 2020.  Antanas Masevicius
+ *
+ *  This source code is licensed under the MIT license found in the
+ *  LICENSE file in the root directory of this source tree.
+ *
+ */
+
+package commands
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestParseToolVersion(t *testing.T) {
+	tests := []struct {
+		name       string
+		out        string
+		importPath string
+		want       string
+	}{
+		{
+			name: "main module",
+			out: "/bin/goimports: go1.21.0\n" +
+				"\tpath\tgolang.org/x/tools/cmd/goimports\n" +
+				"\tmod\tgolang.org/x/tools\tv0.1.12\th1:abc=\n" +
+				"\tdep\tgolang.org/x/mod\tv0.6.0\th1:def=\n",
+			importPath: goImportsImportPath,
+			want:       "v0.1.12",
+		},
+		{
+			name: "longest matching dependency",
+			out: "/bin/tool: go1.21.0\n" +
+				"\tpath\texample.com/tools\n" +
+				"\tmod\texample.com/tools\t(devel)\t\n" +
+				"\tdep\tgolang.org/x\tv0.1.0\th1:abc=\n" +
+				"\tdep\tgolang.org/x/tools\tv0.2.0\th1:def=\n",
+			importPath: goImportsImportPath,
+			want:       "v0.2.0",
+		},
+		{
+			name: "replaced module",
+			out: "/bin/goimports: go1.21.0\n" +
+				"\tmod\tgolang.org/x/tools\tv0.1.12\t\n" +
+				"\t=>\t../tools\t(devel)\t\n" +
+				"\tdep\tgolang.org/x/mod\tv0.6.0\th1:def=\n",
+			importPath: goImportsImportPath,
+			want:       "",
+		},
+		{
+			name:       "development build",
+			out:        "/bin/goimports: go1.21.0\n\tmod\tgolang.org/x/tools\t(devel)\t\n",
+			importPath: goImportsImportPath,
+			want:       "",
+		},
+		{
+			name:       "prefix of another module",
+			out:        "/bin/golint: go1.21.0\n\tmod\tgolang.org/x/lintx\tv1.0.0\t\n",
+			importPath: goLintImportPath,
+			want:       "",
+		},
+		{
+			name:       "no build info",
+			out:        "",
+			importPath: goLintImportPath,
+			want:       "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseToolVersion(tt.out, tt.importPath); got != tt.want {
+				t.Errorf("parseToolVersion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindToolResolutionOrder(t *testing.T) {
+	binDir, err := ioutil.TempDir("", "tools-bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(binDir)
+	pathDir, err := ioutil.TempDir("", "tools-path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pathDir)
+
+	const name = "go-ci-fake-tool"
+	inBinDir := filepath.Join(binDir, name)
+	inPath := filepath.Join(pathDir, name)
+	for _, p := range []string{inBinDir, inPath} {
+		if err := ioutil.WriteFile(p, []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer setEnv(t, "PATH", pathDir+string(os.PathListSeparator)+os.Getenv("PATH"))()
+	defer setToolsBinDir(binDir)()
+
+	if got, ok := findTool(name); !ok || got != inBinDir {
+		t.Errorf("with ToolsBinDir findTool() = %q, %v, want %q", got, ok, inBinDir)
+	}
+
+	if err := os.Remove(inBinDir); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := findTool(name); ok {
+		t.Errorf("with ToolsBinDir missing the tool findTool() = %q, want it not found outside ToolsBinDir", got)
+	}
+
+	ToolsBinDir = ""
+	if got, ok := findTool(name); !ok || got != inPath {
+		t.Errorf("without ToolsBinDir findTool() = %q, %v, want %q", got, ok, inPath)
+	}
+}
+
+func TestEnsureToolReinstallsMismatchedVersion(t *testing.T) {
+	const (
+		importPath = "example.com/fixturetool"
+		version    = "v1.0.0"
+	)
+	proxyDir, err := ioutil.TempDir("", "tools-proxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(proxyDir)
+	writeFixtureModuleProxy(t, proxyDir, importPath, version, map[string]string{
+		"go.mod":  "module " + importPath + "\n\ngo 1.14\n",
+		"main.go": "package main\n\nfunc main() {}\n",
+	})
+	defer setEnv(t, "GOPROXY", "file://"+filepath.ToSlash(proxyDir))()
+	defer setEnv(t, "GONOSUMDB", importPath)()
+	defer setEnv(t, "GOFLAGS", "-mod=mod -modcacherw")()
+	modCache, err := ioutil.TempDir("", "tools-modcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(modCache)
+	defer setEnv(t, "GOMODCACHE", modCache)()
+
+	binDir, err := ioutil.TempDir("", "tools-bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(binDir)
+	defer setToolsBinDir(binDir)()
+
+	// a binary without build info stands for a tool at another version
+	stale := filepath.Join(binDir, toolBinaryName(importPath))
+	if runtime.GOOS == "windows" {
+		stale += ".exe"
+	}
+	if err := ioutil.WriteFile(stale, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := EnsureTool(importPath, version)
+	if err != nil {
+		t.Fatalf("EnsureTool() error = %v", err)
+	}
+	if got != stale {
+		t.Errorf("EnsureTool() = %q, want %q", got, stale)
+	}
+	if v := toolVersion(got, importPath); v != version {
+		t.Errorf("installed version = %q, want %q", v, version)
+	}
+}
+
+// writeFixtureModuleProxy lays out a single module version in dir the way a GOPROXY file:// URL serves it
+func writeFixtureModuleProxy(t *testing.T, dir, modPath, version string, files map[string]string) {
+	vDir := filepath.Join(dir, filepath.FromSlash(modPath), "@v")
+	if err := os.MkdirAll(vDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(vDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("list", version+"\n")
+	write(version+".info", `{"Version":"`+version+`","Time":"2020-01-01T00:00:00Z"}`)
+	write(version+".mod", files["go.mod"])
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(modPath + "@" + version + "/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	write(version+".zip", buf.String())
+}
+
+// setToolsBinDir sets ToolsBinDir and returns a func restoring the previous value
+func setToolsBinDir(dir string) func() {
+	prev := ToolsBinDir
+	ToolsBinDir = dir
+	return func() { ToolsBinDir = prev }
+}
+
+// setEnv sets an environment variable and returns a func restoring the previous value
+func setEnv(t *testing.T, key, value string) func() {
+	prev, had := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		if had {
+			os.Setenv(key, prev)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"go/build"
 	"os"
 	"path"
+	"strconv"
 
 	"github.com/magefile/mage/sh"
 )
@@ -47,3 +48,9 @@ func GetGoBinaryPath(binaryName string) (string, error) {
 	}
 	return binaryUnderGopath, nil
 }
+
+// IsCI returns true when running in a CI environment, as indicated by the CI env variable
+func IsCI() bool {
+	ci, _ := strconv.ParseBool(os.Getenv("CI"))
+	return ci
+}
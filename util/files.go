@@ -18,6 +18,7 @@
 package util
 
 import (
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -57,6 +58,36 @@ func GetPackagePathsWithExcludes(path string, excludes ...string) ([]string, err
 	return result, nil
 }
 
+// GetPackagePathsWithDirExcludes returns the import paths of the packages matched by the pattern
+// whose directory, relative to the working directory, is not excluded by IsPathExcluded
+func GetPackagePathsWithDirExcludes(pattern string, excludes ...string) ([]string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	res, err := exec.Command("go", "list", "-f", "{{.ImportPath}}\t{{.Dir}}", pattern).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0)
+	for _, line := range strings.Split(string(res), "\n") {
+		fields := strings.SplitN(line, "\t", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		dir, err := filepath.Rel(wd, fields[1])
+		if err != nil {
+			return nil, err
+		}
+		if dir != "." && IsPathExcluded(excludes, dir) {
+			continue
+		}
+		result = append(result, fields[0])
+	}
+	return result, nil
+}
+
 // GetPackagePaths gets the go paths for various checks
 func GetPackagePaths(path string) ([]string, error) {
 	cmd := exec.Command("go", "list", path)
@@ -69,6 +100,15 @@ func GetPackagePaths(path string) ([]string, error) {
 	return splits, nil
 }
 
+// GetModulePath returns the path of the main module, or an empty string when not in module mode
+func GetModulePath() string {
+	res, err := exec.Command("go", "list", "-m").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.Split(string(res), "\n")[0])
+}
+
 // GoLintExcludes returns commonly excluded dirs from quality checks
 func GoLintExcludes() []string {
 	return []string{
@@ -92,3 +132,39 @@ func GetProjectFileDirectories(paths []string) ([]string, error) {
 	})
 	return directories, err
 }
+
+// GetProjectDirectoriesWithRoot returns the project directories like GetProjectFileDirectories, starting with the root directory "."
+func GetProjectDirectoriesWithRoot(paths []string) ([]string, error) {
+	dirs, err := GetProjectFileDirectories(paths)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{"."}, dirs...), nil
+}
+
+// GetProjectGoFiles returns the .go files of the project, including the ones in the root directory
+func GetProjectGoFiles(paths []string) ([]string, error) {
+	dirs, err := GetProjectDirectoriesWithRoot(paths)
+	if err != nil {
+		return nil, err
+	}
+	return GetGoFiles(dirs)
+}
+
+// GetGoFiles returns the .go files located directly in the given directories
+func GetGoFiles(dirs []string) ([]string, error) {
+	files := make([]string, 0)
+	for _, dir := range dirs {
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return files, err
+		}
+		for _, info := range infos {
+			if info.IsDir() || filepath.Ext(info.Name()) != ".go" {
+				continue
+			}
+			files = append(files, filepath.Join(dir, info.Name()))
+		}
+	}
+	return files, nil
+}